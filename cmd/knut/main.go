@@ -4,12 +4,17 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	qrcode "github.com/skip2/go-qrcode"
 
@@ -18,8 +23,16 @@ import (
 	"github.com/mgumz/knut/internal/pkg/knut/ui"
 )
 
+// how long a stream mapping with "exit" waits for the other requests
+// before knut stops
+const shutdownTimeout = 5 * time.Second
+
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(replayMain(os.Args[2:]))
+	}
+
 	opts := knut.SetupFlags(flag.CommandLine)
 
 	flag.CommandLine.SetOutput(os.Stdout)
@@ -38,7 +51,8 @@ func main() {
 		os.Exit(1)
 	}
 
-	tree, windows := prepareTrees(http.NewServeMux(), flag.Args())
+	env := newTreeEnv(opts, flag.Args())
+	tree, windows := prepareTrees(http.NewServeMux(), flag.Args(), env)
 	if len(windows) == 0 {
 		fmt.Fprintf(os.Stderr, "error: not one valid mapping given\n")
 		flag.Usage()
@@ -48,18 +62,35 @@ func main() {
 	if opts.DoIndexHandler {
 		tree.Handle("/", handler.IndexHandler(windows))
 	}
+	if env.liveReload != nil {
+		tree.Handle(handler.LiveReloadPath, env.liveReload)
+	}
 
-	h := buildHandlerChain(tree, opts)
-	run := makeRunner(opts, h)
+	h := buildHandlerChain(tree, opts, env.console)
+	server, run := makeRunner(opts, h)
 
-	fmt.Printf("\nknut started on %s, be aware of the trees!\n\n", opts.BindAddr)
+	fmt.Fprintf(env.console, "\nknut started on %s, be aware of the trees!\n\n", opts.BindAddr)
 
-	showQR(opts)
+	showQR(opts, env.console)
 
-	if err := run(); err != nil {
-		fmt.Printf("error: %v\n", err)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-env.delivered
+		// lets the delivery finish. streaming clients (live-reload, bin
+		// events) never go idle, they are cut after the deadline
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			server.Close()
+		}
+	}()
+
+	if err := run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(env.console, "error: %v\n", err)
 		os.Exit(1)
 	}
+	<-done // Serve returns as soon as Shutdown starts
 }
 
 // fatal prints a message to stderr and exits with status 1.
@@ -89,16 +120,21 @@ func resolveBindAddr(opts *knut.Opts) string {
 }
 
 // buildHandlerChain wraps the muxer with the optional middleware selected via
-// opts. Order matters: the outermost wrapper runs first per request.
-func buildHandlerChain(tree http.Handler, opts *knut.Opts) http.Handler {
+// opts. Order matters: the outermost wrapper runs first per request. logs
+// and dumps go to 'console'.
+func buildHandlerChain(tree http.Handler, opts *knut.Opts, console io.Writer) http.Handler {
 	h := tree
 
 	if opts.AddServerID != "" {
 		h = handler.AddServerIDHandler(h, opts.AddServerID)
 	}
-	h = handler.NoCacheHandler(h)
+	policy, err := handler.ParseCachePolicy(opts.CachePolicy)
+	if err != nil {
+		fatal("parsing -cache: %v", err)
+	}
+	h = handler.CachePolicyHandler(h, policy, "")
 	if opts.DoCompress {
-		h = handler.CompressHandler(h)
+		h = handler.CompressHandler(h, opts.CompressMinSize)
 	}
 	if opts.DoAuth != "" {
 		parts := strings.SplitN(opts.DoAuth, ":", 2)
@@ -107,33 +143,55 @@ func buildHandlerChain(tree http.Handler, opts *knut.Opts) http.Handler {
 		}
 		h = handler.BasicAuthHandler(h, parts[0], parts[1])
 	}
+	if opts.Capture != "" {
+		var sink handler.ExchangeSink
+		var err error
+		switch opts.CaptureFormat {
+		case "har":
+			sink, err = handler.NewHARCapture(opts.Capture, knut.Version)
+		case "http":
+			sink, err = handler.NewHTTPCapture(opts.Capture)
+		default:
+			err = fmt.Errorf("unknown format %q", opts.CaptureFormat)
+		}
+		if err != nil {
+			fatal("-capture: %v", err)
+		}
+		h = handler.CaptureHandler(h, sink, opts.CaptureMaxBody)
+	}
 	if opts.DoLog {
-		h = handler.LogRequestHandler(h, os.Stdout)
+		h = handler.LogRequestHandler(h, console)
 	}
 	if opts.DoTeeBody {
-		h = handler.FlushBodyHandler(h)
-		h = handler.TeeBodyHandler(h, os.Stdout)
+		h = handler.TeeBodyHandler(h, console)
 	}
 
 	return h
 }
 
-// makeRunner returns the serve function selected by the TLS options.
-func makeRunner(opts *knut.Opts, h http.Handler) func() error {
+// makeRunner returns the server and its serve function selected by the
+// TLS options.
+func makeRunner(opts *knut.Opts, h http.Handler) (*http.Server, func() error) {
+	server := &http.Server{Addr: opts.BindAddr, Handler: h}
+	clientAuth := tls.NoClientCert
+	if opts.TlsClientCert {
+		clientAuth = tls.RequestClientCert
+	}
 	switch {
 	case opts.TlsOnetime:
-		onetime := &knut.OnetimeTLS{}
+		onetime := &knut.OnetimeTLS{ClientAuth: clientAuth}
 		if err := onetime.Create(opts.BindAddr); err != nil {
 			fatal("%v", err)
 		}
-		return func() error { return http.Serve(onetime.Listener, h) }
+		return server, func() error { return server.Serve(onetime.Listener) }
 	case opts.TlsCert != "" && opts.TlsKey != "":
-		return func() error {
-			server := &http.Server{Addr: opts.BindAddr, Handler: h}
-			return server.ListenAndServeTLS(opts.TlsCert, opts.TlsKey)
-		}
+		server.TLSConfig = &tls.Config{ClientAuth: clientAuth}
+		return server, func() error { return server.ListenAndServeTLS(opts.TlsCert, opts.TlsKey) }
 	default:
-		return func() error { return http.ListenAndServe(opts.BindAddr, h) }
+		if opts.TlsClientCert {
+			fmt.Fprintf(os.Stderr, "warning: -tls-client-cert needs -tls-onetime or -tls-cert and -tls-key\n")
+		}
+		return server, server.ListenAndServe
 	}
 }
 
@@ -141,7 +199,7 @@ func makeRunner(opts *knut.Opts, h http.Handler) func() error {
 //
 // NOTE: maybe needed one day: the QR code will scroll out if enough
 // requests were served (and logged). maybe not a problem for now.
-func showQR(opts *knut.Opts) {
+func showQR(opts *knut.Opts, console io.Writer) {
 	if !opts.DoShowQR {
 		return
 	}
@@ -154,5 +212,5 @@ func showQR(opts *knut.Opts) {
 	url := fmt.Sprintf("%s://%s/", proto, opts.BindAddr)
 	qr, _ := qrcode.New(url, qrcode.Medium)
 
-	fmt.Println(qr.ToString(true))
+	fmt.Fprintln(console, qr.ToString(true))
 }
@@ -4,30 +4,93 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mgumz/knut/internal/pkg/knut"
 	kh "github.com/mgumz/knut/internal/pkg/knut/handler"
+	"github.com/mgumz/knut/internal/pkg/knut/ui"
 )
 
+// treeEnv carries the state shared by all mappings
+type treeEnv struct {
+	liveReload *kh.LiveReload
+	auth       bool
+	console    io.Writer // for knut's own output, stdout might carry a stream
+	stdinTaken bool
+	delivered  chan struct{} // closed when a stream mapping with "exit" is done
+	deliver    func()
+}
+
+// newTreeEnv prepares the shared state selected via opts
+func newTreeEnv(opts *knut.Opts, mappings []string) *treeEnv {
+	env := &treeEnv{auth: opts.DoAuth != "", console: os.Stdout, delivered: make(chan struct{})}
+	env.deliver = sync.OnceFunc(func() { close(env.delivered) })
+	if opts.DoLiveReload {
+		env.liveReload = kh.NewLiveReload(opts.LiveReloadInterval)
+	}
+	for _, mapping := range mappings {
+		if window, tree, err := knut.GetWindowAndTree(mapping); err == nil && window[0] == '@' && isStreamTree(tree) {
+			env.console = os.Stderr
+		}
+	}
+	return env
+}
+
+// isStreamTree reports if 'tree' is "-" (knut's stdin or stdout) with
+// optional query-options, "-?every&exit".
+func isStreamTree(tree string) bool {
+	return tree == "-" || strings.HasPrefix(tree, "-?")
+}
+
+// streamOptions returns the query-options of the stream 'tree' and the
+// function to call once the stream was delivered.
+func (env *treeEnv) streamOptions(tree string) (url.Values, func()) {
+	query, _ := url.ParseQuery(strings.TrimPrefix(strings.TrimPrefix(tree, "-"), "?"))
+	if knut.HasQueryParam("exit", query) {
+		return query, env.deliver
+	}
+	return query, nil
+}
+
+// dirHandler applies the directory related options to 'handler', which
+// serves 'path' via 'window'
+func (env *treeEnv) dirHandler(handler http.Handler, path, window string) http.Handler {
+	if fi, err := os.Stat(path); err != nil || !fi.IsDir() {
+		return handler
+	}
+	if env.liveReload != nil {
+		env.liveReload.Watch(path, window)
+		handler = env.liveReload.Inject(handler)
+	}
+	return handler
+}
+
 // prepareTrees binds a list of uri:tree pairs to 'muxer'
-func prepareTrees(muxer *http.ServeMux, mappings []string) (*http.ServeMux, []string) {
+func prepareTrees(muxer *http.ServeMux, mappings []string, env *treeEnv) (*http.ServeMux, []string) {
 
 	windows := []string{}
 
 	for i := range mappings {
-		window, tree, handler, verb, ok := handlerForMapping(mappings[i], i)
+		window, tree, handler, verb, ok := handlerForMapping(mappings[i], i, env)
 		if !ok {
 			continue
 		}
 
-		fmt.Printf("knut %s %q through %q\n", verb, tree, window)
+		fmt.Fprintf(env.console, "knut %s %q through %q\n", verb, tree, window)
 		muxer.Handle(window, handler)
+		if verb == "catches" && !strings.HasSuffix(window, "/") {
+			muxer.Handle(window+"/", handler) // uploads to "window/<name>"
+		}
 		windows = append(windows, window)
 	}
 
@@ -36,7 +99,7 @@ func prepareTrees(muxer *http.ServeMux, mappings []string) (*http.ServeMux, []st
 
 // handlerForMapping parses a single uri:tree pair and builds its handler.
 // ok=false means the mapping warned (if needed) and must be skipped.
-func handlerForMapping(mapping string, pos int) (window, tree string, handler http.Handler, verb string, ok bool) {
+func handlerForMapping(mapping string, pos int, env *treeEnv) (window, tree string, handler http.Handler, verb string, ok bool) {
 
 	const (
 		UPLOAD_HANDLER = '@'
@@ -56,11 +119,25 @@ func handlerForMapping(mapping string, pos int) (window, tree string, handler ht
 			fmt.Fprintf(os.Stderr, "warning: post uri in pair %d is empty\n", pos)
 			return "", "", nil, "", false
 		}
-		if fi, err := os.Stat(tree); err == nil && !fi.IsDir() {
-			fmt.Fprintf(os.Stderr, "warning: existing %q is not a directory\n", tree)
+		if isStreamTree(tree) {
+			// @/in:- or @/in:-?exit
+			_, received := env.streamOptions(tree)
+			return window, "stdout", kh.StreamReceiveHandler(os.Stdout, received), "pours into", true
+		}
+		// @/upload:folder or @/upload:file://folder?naming=..&collision=..
+		dir, query := tree, url.Values{}
+		if treeURL, err := url.Parse(tree); err == nil && treeURL.Scheme == "file" {
+			dir, query = knut.LocalFilename(treeURL), treeURL.Query()
+		}
+		if fi, err := os.Stat(dir); err == nil && !fi.IsDir() {
+			fmt.Fprintf(os.Stderr, "warning: existing %q is not a directory\n", dir)
+			return "", "", nil, "", false
+		}
+		opts, skip := uploadOptions(dir, query, window)
+		if skip {
 			return "", "", nil, "", false
 		}
-		handler, verb = kh.UploadHandler(tree), "catches"
+		handler, verb = kh.UploadHandler(dir, window, opts), "catches"
 	case strings.HasPrefix(window, "200"):
 		if window = window[3:]; window == "" {
 			fmt.Fprintf(os.Stderr, "warning: 20x path in pair %d is empty\n", pos)
@@ -73,17 +150,31 @@ func handlerForMapping(mapping string, pos int) (window, tree string, handler ht
 			return "", "", nil, "", false
 		}
 		handler, verb = kh.RedirectHandler(window, tree), "points at"
+	case isStreamTree(tree):
+		// /out:- or /out:-?every&exit
+		if env.stdinTaken {
+			fmt.Fprintf(os.Stderr, "warning: %q: stdin is served by another mapping already\n", window)
+			return "", "", nil, "", false
+		}
+		env.stdinTaken = true
+		query, delivered := env.streamOptions(tree)
+		handler = kh.StreamDownloadHandler(os.Stdin, window, knut.HasQueryParam("every", query), delivered)
+		tree = "stdin"
 	case tree[0] == STRING_HANDLER:
 		handler = kh.ServeStringHandler(tree[1:])
 	default:
 		if treeURL, err := url.Parse(tree); err == nil {
 			var skip bool
-			if handler, skip = schemeHandler(treeURL, window); skip {
+			if handler, skip = schemeHandler(treeURL, window, env); skip {
 				return "", "", nil, "", false
 			}
+			switch treeURL.Scheme {
+			case "tus", "paste", "pipe", "bin":
+				verb = "catches"
+			}
 		}
 		if handler == nil {
-			handler = kh.FileOrDirHandler(tree, window)
+			handler = env.dirHandler(kh.FileOrDirHandler(tree, window), tree, window)
 		}
 	}
 
@@ -92,16 +183,36 @@ func handlerForMapping(mapping string, pos int) (window, tree string, handler ht
 
 // schemeHandler builds a handler from tree's URL scheme.
 // skip=true means the mapping warned and must be skipped.
-func schemeHandler(treeURL *url.URL, window string) (http.Handler, bool) {
+func schemeHandler(treeURL *url.URL, window string, env *treeEnv) (http.Handler, bool) {
 	query := treeURL.Query()
 	switch treeURL.Scheme {
 	case "http", "https":
 		return httputil.NewSingleHostReverseProxy(treeURL), false
 	case "file":
-		return kh.FileOrDirHandler(knut.LocalFilename(treeURL), window), false
+		// file://dist?spa&base=/app/&cache=assets/*=365d:immutable
+		// file://share?writable&versions=10&versions-age=30d
+		path := knut.LocalFilename(treeURL)
+		handler := kh.FileOrDirHandler(path, window)
+		switch {
+		case knut.HasQueryParam("writable", query):
+			if !env.auth {
+				fmt.Fprintf(os.Stderr, "warning: %q is writable for everyone, consider -auth\n", window)
+			}
+			versions, skip := versionStore(path, query, window)
+			if skip {
+				return nil, true
+			}
+			handler = kh.FileManagerHandler(path, window, versions)
+		case knut.HasQueryParam("spa", query):
+			handler = kh.SPAHandler(path, window, query.Get("base"))
+		}
+		handler = env.dirHandler(handler, path, window)
+		return withCachePolicy(handler, query, window)
 	case "myip":
 		// myip://?fuzzy&info=ripe
 		return kh.MyIPHandler(query.Get("info"), query.Has("fuzzy")), false
+	case "echo":
+		return kh.EchoHandler(), false
 	case "qr":
 		qrContent := treeURL.Path
 		if len(qrContent) <= 1 {
@@ -130,10 +241,256 @@ func schemeHandler(treeURL *url.URL, window string) (http.Handler, bool) {
 		store := knut.HasQueryParam("store", query)
 		handler := kh.ZipHandler(knut.LocalFilename(treeURL), prefix, store)
 		return kh.SetContentType(handler, "application/zip"), false
+	case "webdav", "webdav+ro":
+		path := knut.LocalFilename(treeURL)
+		readOnly := treeURL.Scheme == "webdav+ro"
+		versions, skip := versionStore(path, query, window)
+		if skip {
+			return nil, true
+		}
+		return kh.WebDAVHandler(path, window, readOnly, versions), false
+	case "tus":
+		// tus://folder?expire=24h&naming=..&max-file-size=..
+		path := knut.LocalFilename(treeURL)
+		opts, skip := uploadOptions(path, query, window)
+		if skip {
+			return nil, true
+		}
+		expire := 24 * time.Hour
+		if v := query.Get("expire"); v != "" {
+			var err error
+			if expire, err = kh.ParseDuration(v); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %q: invalid expire %q\n", window, v)
+				return nil, true
+			}
+		}
+		return kh.TusHandler(path, window, expire, opts), false
+	case "pipe":
+		// pipe://?timeout=5m
+		timeout := 5 * time.Minute
+		if v := query.Get("timeout"); v != "" {
+			var err error
+			if timeout, err = kh.ParseDuration(v); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %q: invalid timeout %q\n", window, v)
+				return nil, true
+			}
+		}
+		return kh.PipeHandler(window, timeout), false
+	case "paste":
+		// paste://dir?expire=7d&max-size=1M&list=operator
+		opts := kh.PasteOptions{Expire: 7 * 24 * time.Hour, MaxSize: 1 << 20, List: kh.RecentOperator}
+		var err error
+		if v := query.Get("expire"); v != "" {
+			if opts.Expire, err = kh.ParseDuration(v); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %q: invalid expire %q\n", window, v)
+				return nil, true
+			}
+		}
+		if v := query.Get("max-size"); v != "" {
+			if opts.MaxSize, err = kh.ParseByteSize(v); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %q: max-size: %v\n", window, err)
+				return nil, true
+			}
+		}
+		switch v := query.Get("list"); v {
+		case "":
+		case kh.RecentOff, kh.RecentOperator, kh.RecentAll:
+			opts.List = v
+		default:
+			fmt.Fprintf(os.Stderr, "warning: %q: invalid list %q\n", window, v)
+			return nil, true
+		}
+		return kh.PasteHandler(knut.LocalFilename(treeURL), window, opts), false
+	case "bin":
+		// bin://folder?keep=100&max-body=1M&view=operator
+		opts := kh.BinOptions{Keep: 100, MaxBody: 1 << 20, View: kh.RecentOperator}
+		if v := query.Get("keep"); v != "" {
+			keep, err := strconv.Atoi(v)
+			if err != nil || keep < 1 {
+				fmt.Fprintf(os.Stderr, "warning: %q: invalid keep %q\n", window, v)
+				return nil, true
+			}
+			opts.Keep = keep
+		}
+		if v := query.Get("max-body"); v != "" {
+			var err error
+			if opts.MaxBody, err = kh.ParseByteSize(v); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %q: max-body: %v\n", window, err)
+				return nil, true
+			}
+		}
+		switch v := query.Get("view"); v {
+		case "":
+		case kh.RecentOperator, kh.RecentAll:
+			opts.View = v
+		default:
+			fmt.Fprintf(os.Stderr, "warning: %q: invalid view %q\n", window, v)
+			return nil, true
+		}
+		return kh.BinHandler(knut.LocalFilename(treeURL), window, opts), false
 	case "zipfs":
 		prefix := query.Get("prefix")
 		index := query.Get("index")
-		return kh.ZipFSHandler(knut.LocalFilename(treeURL), prefix, index), false
+		handler := kh.ZipFSHandler(knut.LocalFilename(treeURL), prefix, index)
+		return withCachePolicy(handler, query, window)
 	}
 	return nil, false
 }
+
+// withCachePolicy wraps 'handler' with the "cache" rules given in 'query',
+// these override the global -cache rules.
+// skip=true means the rules are invalid and the mapping must be skipped.
+func withCachePolicy(handler http.Handler, query url.Values, window string) (http.Handler, bool) {
+	if !knut.HasQueryParam("cache", query) {
+		return handler, false
+	}
+	policy, err := kh.ParseCachePolicy(query.Get("cache"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %q: %v\n", window, err)
+		return nil, true
+	}
+	return kh.CachePolicyHandler(handler, policy, window), false
+}
+
+// versionStore creates the store for the revisions of the files in 'path'
+// if "versions" is given in 'query': "versions=n" keeps the last n
+// revisions of a file (default 10, 0 means all), "versions-age" drops
+// older revisions.
+// skip=true means the options are invalid and the mapping must be skipped.
+func versionStore(path string, query url.Values, window string) (*kh.VersionStore, bool) {
+	if !knut.HasQueryParam("versions", query) {
+		return nil, false
+	}
+	keep, maxAge := 10, time.Duration(0)
+	if v := query.Get("versions"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			fmt.Fprintf(os.Stderr, "warning: %q: invalid versions %q\n", window, v)
+			return nil, true
+		}
+		keep = n
+	}
+	if v := query.Get("versions-age"); v != "" {
+		age, err := kh.ParseDuration(v)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %q: invalid versions-age %q\n", window, v)
+			return nil, true
+		}
+		maxAge = age
+	}
+	return kh.NewVersionStore(path, keep, maxAge), false
+}
+
+// uploadOptions builds the options of an upload mapping from 'query'.
+// skip=true means the options are invalid and the mapping must be skipped.
+func uploadOptions(dir string, query url.Values, window string) (kh.UploadOptions, bool) {
+	opts := kh.UploadOptions{}
+	naming, err := kh.ParseUploadNaming(query.Get("naming"), query.Get("collision"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %q: %v\n", window, err)
+		return opts, true
+	}
+	opts.Naming = naming
+
+	sizes := []struct {
+		key string
+		val *int64
+	}{
+		{"max-file-size", &opts.Limits.MaxFileSize},
+		{"max-request-size", &opts.Limits.MaxRequestSize},
+		{"quota", &opts.Limits.Quota},
+	}
+	for _, size := range sizes {
+		if v := query.Get(size.key); v != "" {
+			if *size.val, err = kh.ParseByteSize(v); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %q: %s: %v\n", window, size.key, err)
+				return opts, true
+			}
+		}
+	}
+	if v := query.Get("max-files"); v != "" {
+		if opts.Limits.MaxFiles, err = strconv.Atoi(v); err != nil || opts.Limits.MaxFiles < 0 {
+			fmt.Fprintf(os.Stderr, "warning: %q: invalid max-files %q\n", window, v)
+			return opts, true
+		}
+	}
+	switch opts.Recent = query.Get("recent"); opts.Recent {
+	case "":
+		opts.Recent = kh.RecentOperator
+	case kh.RecentOff, kh.RecentOperator, kh.RecentAll:
+	default:
+		fmt.Fprintf(os.Stderr, "warning: %q: invalid recent %q\n", window, opts.Recent)
+		return opts, true
+	}
+	if v := query.Get("allow"); v != "" {
+		opts.Limits.Allow = strings.Split(v, ",")
+	}
+	if v := query.Get("deny"); v != "" {
+		opts.Limits.Deny = strings.Split(v, ",")
+	}
+
+	if command := query.Get("hook"); command != "" {
+		timeout, concurrency := time.Minute, 1
+		if v := query.Get("hook-timeout"); v != "" {
+			if timeout, err = kh.ParseDuration(v); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %q: invalid hook-timeout %q\n", window, v)
+				return opts, true
+			}
+		}
+		if v := query.Get("hook-concurrency"); v != "" {
+			if concurrency, err = strconv.Atoi(v); err != nil || concurrency < 1 {
+				fmt.Fprintf(os.Stderr, "warning: %q: invalid hook-concurrency %q\n", window, v)
+				return opts, true
+			}
+		}
+		if opts.Hook, err = kh.NewUploadHook(command, timeout, concurrency, query.Get("hook-fail")); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %q: %v\n", window, err)
+			return opts, true
+		}
+	}
+
+	if knut.HasQueryParam("extract", query) {
+		ex := &kh.Extractor{MaxEntries: 10000, MaxSize: 1 << 30}
+		switch v := query.Get("extract"); v {
+		case "", "keep":
+			ex.KeepArchive = true
+		case "discard":
+		default:
+			fmt.Fprintf(os.Stderr, "warning: %q: invalid extract %q\n", window, v)
+			return opts, true
+		}
+		if v := query.Get("extract-max-entries"); v != "" {
+			if ex.MaxEntries, err = strconv.Atoi(v); err != nil || ex.MaxEntries < 0 {
+				fmt.Fprintf(os.Stderr, "warning: %q: invalid extract-max-entries %q\n", window, v)
+				return opts, true
+			}
+		}
+		if v := query.Get("extract-max-size"); v != "" {
+			if ex.MaxSize, err = kh.ParseByteSize(v); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %q: extract-max-size: %v\n", window, err)
+				return opts, true
+			}
+		}
+		opts.Extract = ex
+	}
+
+	if knut.HasQueryParam("approve", query) {
+		timeout := 5 * time.Minute
+		if v := query.Get("approve-timeout"); v != "" {
+			if timeout, err = kh.ParseDuration(v); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %q: invalid approve-timeout %q\n", window, v)
+				return opts, true
+			}
+		}
+		opts.Approval = kh.NewUploadApproval(func(ctx context.Context, p kh.PendingUpload) (bool, bool, error) {
+			answer, err := ui.PromptUploadApproval(ctx, p.Remote, p.Name, p.Size, p.Type)
+			return answer == ui.UploadAccept, answer == ui.UploadAcceptAll, err
+		}, timeout)
+	}
+
+	var skip bool
+	if opts.Versions, skip = versionStore(dir, query, window); skip {
+		return opts, true
+	}
+	return opts, false
+}
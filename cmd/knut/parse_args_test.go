@@ -0,0 +1,50 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/mgumz/knut/internal/pkg/knut"
+)
+
+func TestHandlerForMappingStreams(t *testing.T) {
+
+	// mappings are relative to the working directory, keep what they
+	// create out of the tree
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	tests := []struct {
+		mapping, tree, created string
+	}{
+		{"@/in:-", "stdout", ""},
+		{"@/in:-?exit", "stdout", ""},
+		{"/out:-?every", "stdin", ""},
+		{"@/upload:folder", "folder", "folder"},
+	}
+
+	for _, test := range tests {
+		env := newTreeEnv(&knut.Opts{}, []string{test.mapping})
+		_, tree, handler, _, ok := handlerForMapping(test.mapping, 0, env)
+		if !ok || handler == nil || tree != test.tree {
+			t.Errorf("%s: expected a handler for %q, got %v %q", test.mapping, test.tree, ok, tree)
+		}
+		entries, _ := os.ReadDir(".")
+		names := []string{}
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		if (test.created == "" && len(names) != 0) || (test.created != "" && (len(names) != 1 || names[0] != test.created)) {
+			t.Errorf("%s: expected %q to be created, got %q", test.mapping, test.created, names)
+		}
+		for _, name := range names {
+			os.RemoveAll(name)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mgumz/knut/internal/pkg/knut"
+)
+
+const replayUsage = `
+knut replay [opts] capture.har
+
+re-sends the requests of a HAR file (see -capture) with their original
+method, path, headers and body to -target and lists the status codes
+next to the recorded ones. exits with 1 if a status differs or a request
+fails.
+
+Sample:
+
+   knut replay -target http://localhost:9000 -concurrency 4 capture.har
+
+Options:
+`
+
+// headerFlags collects repeated "-header 'Name: value'"
+type headerFlags http.Header
+
+func (hf headerFlags) String() string { return "" }
+func (hf headerFlags) Set(v string) error {
+	name, value, ok := strings.Cut(v, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return fmt.Errorf("expected 'Name: value', got %q", v)
+	}
+	http.Header(hf).Add(name, strings.TrimSpace(value))
+	return nil
+}
+
+func replayMain(args []string) int {
+
+	opts := knut.ReplayOpts{Headers: http.Header{}, Concurrency: 1, Timeout: 30 * time.Second}
+
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	fs.SetOutput(os.Stdout)
+	fs.StringVar(&opts.Target, "target", opts.Target, `send the requests to this "scheme://host[:port]"`)
+	fs.StringVar(&opts.Host, "host", opts.Host, `use this "Host" header instead of the one of -target`)
+	fs.Var(headerFlags(opts.Headers), "header", `set "Name: value" on each request, "Name:" removes it (repeatable)`)
+	fs.IntVar(&opts.Concurrency, "concurrency", opts.Concurrency, "number of requests in flight")
+	fs.Float64Var(&opts.Rate, "rate", opts.Rate, "at most this many requests per second (0: no limit)")
+	fs.DurationVar(&opts.Timeout, "timeout", opts.Timeout, "timeout per request (0: none)")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), replayUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || opts.Target == "" {
+		fmt.Fprintf(os.Stderr, "error: need -target and exactly one HAR file\n")
+		fs.Usage()
+		return 1
+	}
+
+	har, err := knut.ReadHAR(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	results, err := knut.Replay(har, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if knut.PrintReplaySummary(os.Stdout, results) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -5,8 +5,12 @@ go 1.23.0
 toolchain go1.25.10
 
 require (
+	github.com/andybalholm/brotli v1.1.1
 	github.com/charmbracelet/huh v1.0.0
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/klauspost/compress v1.18.0
 	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	golang.org/x/net v0.41.0
 )
 
 require (
@@ -35,5 +39,5 @@ require (
 	github.com/xo/terminfo v0.0.0-20220910002029-abceb7e1c41e // indirect
 	golang.org/x/sync v0.15.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
-	golang.org/x/text v0.23.0 // indirect
+	golang.org/x/text v0.26.0 // indirect
 )
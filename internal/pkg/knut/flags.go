@@ -3,45 +3,71 @@
 
 package knut
 
-import "flag"
+import (
+	"flag"
+	"time"
+)
 
 type Opts struct {
 	BindAddr          string
 	DoLog             bool
 	DoAuth            string
 	DoCompress        bool
+	CompressMinSize   int
 	DoInteractiveBind bool
 	DoTeeBody         bool
 	DoIndexHandler    bool
 	DoShowQR          bool
+	DoLiveReload      bool
 	DoPrintVersion    bool
 	AddServerID       string
+	CachePolicy       string
 	TlsOnetime        bool
 	TlsCert           string
 	TlsKey            string
+	TlsClientCert     bool
+	Capture           string
+	CaptureFormat     string
+	CaptureMaxBody    int64
+
+	LiveReloadInterval time.Duration
 }
 
 func SetupFlags(f *flag.FlagSet) *Opts {
 
 	opts := Opts{
-		BindAddr:    ":8080",
-		DoLog:       true,
-		DoCompress:  true,
-		AddServerID: "knut/" + Version,
+		BindAddr:        ":8080",
+		DoLog:           true,
+		DoCompress:      true,
+		CompressMinSize: 1024,
+		AddServerID:     "knut/" + Version,
+		CachePolicy:     "*=no-cache",
+		CaptureFormat:   "har",
+		CaptureMaxBody:  1 << 20,
+
+		LiveReloadInterval: 500 * time.Millisecond,
 	}
 
 	f.StringVar(&opts.BindAddr, "bind", opts.BindAddr, "address to bind to")
 	f.BoolVar(&opts.DoLog, "log", opts.DoLog, "log requests to stdout")
-	f.BoolVar(&opts.DoCompress, "compress", opts.DoCompress, `handle "Accept-Encoding" = "gzip,deflate"`)
+	f.BoolVar(&opts.DoCompress, "compress", opts.DoCompress, `handle "Accept-Encoding" = "zstd,br,gzip,deflate"`)
+	f.IntVar(&opts.CompressMinSize, "compress-min-size", opts.CompressMinSize, `do not compress response bodies smaller than this (in bytes)`)
 	f.BoolVar(&opts.DoInteractiveBind, "select-addr", opts.DoInteractiveBind, `interactively select -bind address`)
 	f.BoolVar(&opts.DoIndexHandler, "serve-index", opts.DoIndexHandler, `create a small index-page, listing the various paths`)
 	f.BoolVar(&opts.DoShowQR, "show-qr", opts.DoShowQR, `show a QR code to stdout pointing to '/' (useful only if -bind is distinct)`)
+	f.BoolVar(&opts.DoLiveReload, "live-reload", opts.DoLiveReload, `reload browsers showing html pages of directory mappings when files change`)
+	f.DurationVar(&opts.LiveReloadInterval, "live-reload-interval", opts.LiveReloadInterval, `collect changes of directory mappings for this long before reloading; the polling interval where inotify is not available`)
 	f.BoolVar(&opts.DoTeeBody, "tee-body", opts.DoTeeBody, `dump request.body to stdout`)
+	f.StringVar(&opts.Capture, "capture", opts.Capture, `record requests and responses into this HAR file or folder of ".http" files`)
+	f.StringVar(&opts.CaptureFormat, "capture-format", opts.CaptureFormat, `"har" or "http" (one file per request)`)
+	f.Int64Var(&opts.CaptureMaxBody, "capture-max-body", opts.CaptureMaxBody, `record at most this many bytes of each body (0: all)`)
 	f.StringVar(&opts.DoAuth, "auth", "", "use 'name:password' to require")
+	f.StringVar(&opts.CachePolicy, "cache", opts.CachePolicy, `"Cache-Control" rules, "glob=directive[:directive],...", eg "*.js=365d:immutable,*=1h"`)
 	f.StringVar(&opts.AddServerID, "server-id", opts.AddServerID, `add "Server: <val-here>" to the response`)
 	f.BoolVar(&opts.TlsOnetime, "tls-onetime", opts.TlsOnetime, "use a onetime-in-memory cert+key to drive tls")
 	f.StringVar(&opts.TlsKey, "tls-key", opts.TlsKey, "use given key to start tls")
 	f.StringVar(&opts.TlsCert, "tls-cert", opts.TlsCert, "use given cert to start tls")
+	f.BoolVar(&opts.TlsClientCert, "tls-client-cert", opts.TlsClientCert, "ask tls clients for a certificate (not verified), see echo://")
 	f.BoolVar(&opts.DoPrintVersion, "version", opts.DoPrintVersion, "print version")
 	f.Usage = func() { printUsage(f) }
 
@@ -0,0 +1,89 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// negotiateContentCoding picks the content-coding out of 'offers' (ordered
+// by preference of the server) the client weighs highest in its
+// Accept-Encoding header, see RFC 9110, section 12.5.3:
+//
+//   - codings with "q=0" are not acceptable
+//   - "*" matches every coding not listed explicitly
+//   - "identity" (no coding, "") is acceptable unless rejected via
+//     "identity;q=0" or "*;q=0"
+//
+// coding == "" means no content-coding should be applied. ok == false
+// means not even "identity" is acceptable.
+func negotiateContentCoding(acceptEncoding string, offers []string) (coding string, ok bool) {
+
+	weights := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		weights[name] = qValue(params)
+	}
+
+	weight := func(coding string) (float64, bool) {
+		if q, exists := weights[coding]; exists {
+			return q, true
+		}
+		if q, exists := weights["*"]; exists {
+			return q, true
+		}
+		return 0, false
+	}
+
+	bestQ := 0.0
+	for _, offer := range offers {
+		if q, _ := weight(offer); q > bestQ {
+			coding, bestQ = offer, q
+		}
+	}
+
+	// an unlisted "identity" is acceptable, but the least preferred
+	identityQ, listed := weight("identity")
+	if coding == "" {
+		return "", !listed || identityQ > 0
+	}
+	if listed && identityQ > bestQ {
+		return "", true
+	}
+	return coding, true
+}
+
+// qValue extracts the "q" weight of the parameters of an Accept-*
+// element. a missing "q" means 1, an invalid one 0.
+func qValue(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(key, "q") {
+			q, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+			if err != nil || q < 0 || q > 1 {
+				return 0
+			}
+			return q
+		}
+	}
+	return 1
+}
+
+// addVary adds 'field' to the "Vary" header, if not already listed.
+func addVary(h http.Header, field string) {
+	for _, vary := range h.Values("Vary") {
+		for _, f := range strings.Split(vary, ",") {
+			if f = strings.TrimSpace(f); f == "*" || strings.EqualFold(f, field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
@@ -0,0 +1,41 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import "testing"
+
+func TestNegotiateContentCoding(t *testing.T) {
+
+	offers := []string{"zstd", "br", "gzip"}
+	tests := []struct {
+		acceptEncoding, result string
+		ok                     bool
+	}{
+		{"", "", true},
+		{"gzip", "gzip", true},
+		{"gzip, br", "br", true},
+		{"gzip;q=0", "", true},
+		{"gzip; q=0.0, deflate", "", true},
+		{"gzip;q=0.5, br;q=0", "gzip", true},
+		{"br;q=0.5, gzip", "gzip", true},
+		{"*", "zstd", true},
+		{"*, zstd;q=0", "br", true},
+		{"identity", "", true},
+		{"GZIP", "gzip", true},
+		{"gzip;q=0.5, identity", "", true},
+		{"gzip;q=0.5, identity;q=0.1", "gzip", true},
+		{"deflate, identity;q=0", "", false},
+		{"*;q=0", "", false},
+		{"gzip;q=invalid", "", true},
+	}
+
+	for i, test := range tests {
+		enc, ok := negotiateContentCoding(test.acceptEncoding, offers)
+		t.Logf("case %d: %q => %q, %v", i, test.acceptEncoding, enc, ok)
+		if enc != test.result || ok != test.ok {
+			t.Errorf("case %d: %q expected %q, %v, got %q, %v",
+				i, test.acceptEncoding, test.result, test.ok, enc, ok)
+		}
+	}
+}
@@ -0,0 +1,514 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// binInspector is the part below the uri of a request bin which is not
+// caught but shows the caught requests.
+const binInspector = "/.knut"
+
+// BinOptions configure BinHandler
+type BinOptions struct {
+	Keep    int    // number of requests kept, at least 1
+	MaxBody int64  // recorded bytes of each body, 0: all
+	View    string // who sees the caught requests, RecentOff if empty
+}
+
+// binRequest is a caught request, kept in memory and, if the bin has a
+// folder, as "<id>.json" in there.
+type binRequest struct {
+	ID        int64       `json:"id"`
+	Time      time.Time   `json:"time"`
+	Duration  float64     `json:"duration_ms"` // receiving the request
+	Remote    string      `json:"remote"`
+	Method    string      `json:"method"`
+	URI       string      `json:"uri"`
+	Proto     string      `json:"proto"`
+	Host      string      `json:"host"`
+	Header    http.Header `json:"headers"`
+	Query     url.Values  `json:"query,omitempty"`
+	Size      int64       `json:"size"`
+	Body      string      `json:"body,omitempty"`
+	Encoding  string      `json:"body_encoding,omitempty"` // "base64" for binary bodies
+	Truncated bool        `json:"truncated,omitempty"`
+
+	// the decoded body, for JSON, url-encoded and multipart bodies
+	JSON  json.RawMessage `json:"json,omitempty"`
+	Form  url.Values      `json:"form,omitempty"`
+	Files []binFile       `json:"files,omitempty"`
+}
+
+type binFile struct {
+	Field string `json:"field"`
+	Name  string `json:"name"`
+	Type  string `json:"type,omitempty"`
+	Size  int64  `json:"size"`
+}
+
+// BinHandler catches any request to 'uri' and below and answers "ok". the
+// last opts.Keep requests are kept, in memory and in 'dir', if given.
+// "uri/.knut/" inspects them, live; "uri/.knut/requests" lists them as
+// JSON, newest first, DELETE clears them; "uri/.knut/requests/<id>" is a
+// single one, ".../<id>/body" its raw body.
+func BinHandler(dir, uri string, opts BinOptions) http.Handler {
+	bh := &binHandler{
+		dir:     dir,
+		uri:     strings.TrimSuffix(uri, "/"),
+		opts:    opts,
+		clients: make(map[chan binEvent]struct{}),
+	}
+	bh.opts.Keep = max(bh.opts.Keep, 1)
+	if dir != "" {
+		os.MkdirAll(dir, 0o777)
+		bh.load()
+	}
+	return bh
+}
+
+type binHandler struct {
+	dir, uri string
+	opts     BinOptions
+
+	mu       sync.Mutex
+	lastID   int64
+	requests []*binRequest // oldest first
+	clients  map[chan binEvent]struct{}
+}
+
+type binEvent struct {
+	kind    string
+	request *binRequest
+}
+
+func (bh *binHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, bh.uri)
+	if rest == binInspector || strings.HasPrefix(rest, binInspector+"/") {
+		bh.inspect(w, r, strings.TrimPrefix(rest, binInspector))
+		return
+	}
+	bh.catch(w, r)
+}
+
+func (bh *binHandler) catch(w http.ResponseWriter, r *http.Request) {
+
+	started := time.Now()
+	body := io.Reader(r.Body)
+	if bh.opts.MaxBody > 0 {
+		body = io.LimitReader(r.Body, bh.opts.MaxBody)
+	}
+	data, err := io.ReadAll(body)
+	size := int64(len(data))
+	if err == nil {
+		var rest int64
+		rest, err = io.Copy(io.Discard, r.Body)
+		size += rest
+	}
+	if err != nil {
+		writeStatus(w, http.StatusBadRequest)
+		return
+	}
+
+	br := &binRequest{
+		Time:      started.UTC(),
+		Duration:  float64(time.Since(started).Microseconds()) / 1000,
+		Remote:    r.RemoteAddr,
+		Method:    r.Method,
+		URI:       r.RequestURI,
+		Proto:     r.Proto,
+		Host:      r.Host,
+		Header:    r.Header.Clone(),
+		Size:      size,
+		Truncated: int64(len(data)) < size,
+	}
+	if query := r.URL.Query(); len(query) > 0 {
+		br.Query = query
+	}
+	if utf8.Valid(data) {
+		br.Body = string(data)
+	} else {
+		br.Body, br.Encoding = base64.StdEncoding.EncodeToString(data), "base64"
+	}
+	if !br.Truncated {
+		br.decodeBody(data)
+	}
+
+	bh.add(br)
+	AddLogNote(r, "bin #%d", br.ID)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, "ok\n")
+}
+
+// decodeBody fills JSON, Form and Files, depending on the type of 'data'.
+func (br *binRequest) decodeBody(data []byte) {
+	ctype, params, _ := mime.ParseMediaType(br.Header.Get("Content-Type"))
+	switch {
+	case ctype == "application/x-www-form-urlencoded":
+		if form, err := url.ParseQuery(string(data)); err == nil && len(form) > 0 {
+			br.Form = form
+		}
+	case ctype == "multipart/form-data":
+		br.Form = url.Values{}
+		mr := multipart.NewReader(bytes.NewReader(data), params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			if part.FileName() != "" {
+				n, _ := io.Copy(io.Discard, part)
+				br.Files = append(br.Files, binFile{part.FormName(), part.FileName(), part.Header.Get("Content-Type"), n})
+				continue
+			}
+			value, _ := io.ReadAll(part)
+			br.Form.Add(part.FormName(), string(value))
+		}
+		if len(br.Form) == 0 {
+			br.Form = nil
+		}
+	default:
+		// webhooks often send JSON as "text/plain" or without any type
+		trimmed := bytes.TrimSpace(data)
+		if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+			br.JSON = trimmed
+		}
+	}
+}
+
+func (bh *binHandler) add(br *binRequest) {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+
+	bh.lastID++
+	br.ID = bh.lastID
+	bh.requests = append(bh.requests, br)
+	for len(bh.requests) > bh.opts.Keep {
+		bh.removeFile(bh.requests[0].ID)
+		bh.requests = bh.requests[1:]
+	}
+	if bh.dir != "" {
+		data, _ := json.Marshal(br)
+		writeFileAtomic(bh.fileName(br.ID), bytes.NewReader(data))
+	}
+	bh.broadcast(binEvent{"request", br})
+}
+
+// load reads the requests kept in the folder of the bin.
+func (bh *binHandler) load() {
+	names, _ := filepath.Glob(filepath.Join(bh.dir, "*.json"))
+	for _, name := range names {
+		id, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(name), ".json"), 10, 64)
+		if err != nil {
+			continue
+		}
+		br := &binRequest{}
+		data, err := os.ReadFile(name)
+		if err == nil {
+			err = json.Unmarshal(data, br)
+		}
+		if err != nil || br.ID != id {
+			continue
+		}
+		bh.requests = append(bh.requests, br)
+	}
+	sort.Slice(bh.requests, func(i, j int) bool { return bh.requests[i].ID < bh.requests[j].ID })
+	if n := len(bh.requests); n > 0 {
+		bh.lastID = bh.requests[n-1].ID
+	}
+	for len(bh.requests) > bh.opts.Keep {
+		bh.removeFile(bh.requests[0].ID)
+		bh.requests = bh.requests[1:]
+	}
+}
+
+func (bh *binHandler) clear() {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+	for _, br := range bh.requests {
+		bh.removeFile(br.ID)
+	}
+	bh.requests = nil
+	bh.broadcast(binEvent{kind: "clear"})
+}
+
+func (bh *binHandler) find(id int64) *binRequest {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+	for _, br := range bh.requests {
+		if br.ID == id {
+			return br
+		}
+	}
+	return nil
+}
+
+// list returns the kept requests, newest first.
+func (bh *binHandler) list() []*binRequest {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+	list := make([]*binRequest, len(bh.requests))
+	for i, br := range bh.requests {
+		list[len(list)-1-i] = br
+	}
+	return list
+}
+
+func (bh *binHandler) fileName(id int64) string {
+	return filepath.Join(bh.dir, fmt.Sprintf("%06d.json", id))
+}
+
+func (bh *binHandler) removeFile(id int64) {
+	if bh.dir != "" {
+		os.Remove(bh.fileName(id))
+	}
+}
+
+// broadcast must be called with bh.mu held.
+func (bh *binHandler) broadcast(event binEvent) {
+	for c := range bh.clients {
+		select {
+		case c <- event:
+		default: // slow client, it reloads the list on "missed"
+			close(c)
+			delete(bh.clients, c)
+		}
+	}
+}
+
+func (bh *binHandler) inspect(w http.ResponseWriter, r *http.Request, rest string) {
+
+	if !showRecent(bh.opts.View, r) {
+		writeStatus(w, http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	switch {
+	case rest == "" || rest == "/":
+		if rest == "" {
+			http.Redirect(w, r, bh.uri+binInspector+"/", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, strings.ReplaceAll(binPage, "{{URI}}", bh.uri+binInspector))
+	case rest == "/events":
+		bh.events(w, r)
+	case rest == "/requests":
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			writeBinJSON(w, r, bh.list())
+		case http.MethodDelete:
+			if !sameOrigin(r) {
+				writeStatus(w, http.StatusForbidden)
+				return
+			}
+			bh.clear()
+			AddLogNote(r, "bin cleared")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeStatus(w, http.StatusMethodNotAllowed)
+		}
+	case strings.HasPrefix(rest, "/requests/"):
+		idText, part, _ := strings.Cut(strings.TrimPrefix(rest, "/requests/"), "/")
+		id, err := strconv.ParseInt(idText, 10, 64)
+		br := bh.find(id)
+		if err != nil || br == nil || (part != "" && part != "body") {
+			http.NotFound(w, r)
+			return
+		}
+		if part == "" {
+			writeBinJSON(w, r, br)
+			return
+		}
+		body := []byte(br.Body)
+		if br.Encoding == "base64" {
+			body, _ = base64.StdEncoding.DecodeString(br.Body)
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"request-%d.bin\"", br.ID))
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		if r.Method != http.MethodHead {
+			w.Write(body)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeBinJSON(w http.ResponseWriter, r *http.Request, v any) {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	data = append(data, '\n')
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if r.Method != http.MethodHead {
+		w.Write(data)
+	}
+}
+
+// events sends each caught request via Server-Sent Events.
+func (bh *binHandler) events(w http.ResponseWriter, r *http.Request) {
+
+	rc := http.NewResponseController(w)
+
+	events := make(chan binEvent, 16)
+	bh.mu.Lock()
+	bh.clients[events] = struct{}{}
+	bh.mu.Unlock()
+	defer func() {
+		bh.mu.Lock()
+		delete(bh.clients, events)
+		bh.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": knut bin\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				fmt.Fprint(w, "event: missed\ndata: {}\n\n")
+				rc.Flush()
+				return
+			}
+			data := []byte("{}")
+			if event.request != nil {
+				data, _ = json.Marshal(event.request)
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.kind, data)
+			rc.Flush()
+		}
+	}
+}
+
+// binPage renders the requests via the JSON API, "{{URI}}" is replaced by
+// the uri of the inspector. everything caught is untrusted, the page only
+// ever sets it as text.
+const binPage = `<!doctype html>
+<html>
+<head>
+	<title>knut - request bin</title>
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<style type="text/css">
+	* { font-family: monospace }
+	details { border-top: 1px solid #ccc; padding: 0.3em 0 }
+	summary { cursor: pointer }
+	td { padding: 0.1em 1em 0.1em 0; vertical-align: top }
+	pre { background: #f6f6f6; padding: 0.5em; overflow-x: auto; white-space: pre-wrap }
+	.dim { color: #888 }
+	</style>
+</head>
+<body>
+<h1>knut - request bin</h1>
+<p><span id="status" class="dim">connecting</span> - <a href="{{URI}}/requests">json</a>
+- <button id="clear">clear</button></p>
+<div id="requests"></div>
+<script>
+(function() {
+	var base = "{{URI}}";
+	var list = document.getElementById("requests");
+	var status = document.getElementById("status");
+
+	function el(tag, text, cls) {
+		var e = document.createElement(tag);
+		if (text !== undefined) { e.textContent = text; }
+		if (cls) { e.className = cls; }
+		return e;
+	}
+	function table(title, values) {
+		var keys = Object.keys(values || {});
+		if (keys.length == 0) { return null; }
+		var div = el("div"), t = el("table");
+		div.appendChild(el("h4", title));
+		keys.sort().forEach(function(k) {
+			[].concat(values[k]).forEach(function(v) {
+				var tr = el("tr");
+				tr.appendChild(el("td", k));
+				tr.appendChild(el("td", v));
+				t.appendChild(tr);
+			});
+		});
+		div.appendChild(t);
+		return div;
+	}
+	function render(r) {
+		var d = el("details");
+		var s = el("summary");
+		s.appendChild(el("b", "#" + r.id + " " + r.method + " " + r.uri));
+		s.appendChild(el("span", "  " + new Date(r.time).toLocaleString() + ", " + r.remote + ", " +
+			r.size + " bytes, " + r.duration_ms + " ms", "dim"));
+		d.appendChild(s);
+		[table("headers", r.headers), table("query", r.query), table("form", r.form)].forEach(function(t) {
+			if (t) { d.appendChild(t); }
+		});
+		(r.files || []).forEach(function(f) {
+			d.appendChild(el("div", "file " + f.field + ": " + f.name + " (" + (f.type || "?") + ", " + f.size + " bytes)"));
+		});
+		if (r.size > 0) {
+			d.appendChild(el("h4", "body"));
+			var note = r.truncated ? " (truncated)" : "";
+			if (r.json !== undefined) {
+				d.appendChild(el("pre", JSON.stringify(r.json, null, 2)));
+			} else if (r.body_encoding == "base64") {
+				note = " (binary)" + note;
+			} else {
+				d.appendChild(el("pre", r.body));
+			}
+			var a = el("a", "download body" + note);
+			a.href = base + "/requests/" + r.id + "/body";
+			d.appendChild(a);
+		}
+		return d;
+	}
+	function load() {
+		fetch(base + "/requests").then(function(resp) { return resp.json(); }).then(function(requests) {
+			list.textContent = "";
+			requests.forEach(function(r) { list.appendChild(render(r)); });
+		});
+	}
+	function connect() {
+		var es = new EventSource(base + "/events");
+		es.onopen = function() { status.textContent = "live"; load(); };
+		es.onerror = function() { status.textContent = "disconnected, retrying"; };
+		es.addEventListener("request", function(e) {
+			list.insertBefore(render(JSON.parse(e.data)), list.firstChild);
+		});
+		es.addEventListener("clear", function() { list.textContent = ""; });
+		es.addEventListener("missed", function() { es.close(); connect(); });
+	}
+	document.getElementById("clear").onclick = function() {
+		fetch(base + "/requests", {method: "DELETE"});
+	};
+	connect();
+})();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,158 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBinCatch(t *testing.T) {
+
+	mp := bytes.NewBuffer(nil)
+	mw := multipart.NewWriter(mp)
+	mw.WriteField("event", "push")
+	fw, _ := mw.CreateFormFile("payload", "p.bin")
+	fw.Write([]byte("1234"))
+	mw.Close()
+
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		ctype     string
+		body      string
+		query     url.Values
+		form      url.Values
+		files     []binFile
+		json      string
+		encoding  string
+		truncated bool
+	}{
+		{"get", "GET", "/hook?a=1&a=2", "", "", url.Values{"a": {"1", "2"}}, nil, nil, "", "", false},
+		{"json", "POST", "/hook/gh", "application/json", ` {"ok": true} `, nil, nil, nil, `{"ok": true}`, "", false},
+		{"json-as-text", "PUT", "/hook", "text/plain", `[1, 2]`, nil, nil, nil, `[1, 2]`, "", false},
+		{"form", "POST", "/hook", "application/x-www-form-urlencoded", "a=b&c=d", nil, url.Values{"a": {"b"}, "c": {"d"}}, nil, "", "", false},
+		{"multipart", "POST", "/hook", mw.FormDataContentType(), mp.String(), nil, url.Values{"event": {"push"}},
+			[]binFile{{"payload", "p.bin", "application/octet-stream", 4}}, "", "", false},
+		{"binary", "POST", "/hook", "", "\xff\xfe", nil, nil, nil, "", "base64", false},
+		{"truncated", "POST", "/hook", "application/json", `{"too": "` + strings.Repeat("x", 1024) + `"}`, nil, nil, nil, "", "", true},
+	}
+
+	h := BinHandler("", "/hook", BinOptions{Keep: 100, MaxBody: 1024, View: RecentAll}).(*binHandler)
+	for i, test := range tests {
+		r := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+		if test.ctype != "" {
+			r.Header.Set("Content-Type", test.ctype)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || w.Body.String() != "ok\n" {
+			t.Errorf("%s: expected ok, got %d %q", test.name, w.Code, w.Body.String())
+			continue
+		}
+		br := h.find(int64(i + 1))
+		if br == nil {
+			t.Errorf("%s: not kept", test.name)
+			continue
+		}
+		if br.Method != test.method || br.URI != test.target || br.Size != int64(len(test.body)) || br.Truncated != test.truncated || br.Encoding != test.encoding {
+			t.Errorf("%s: unexpected request %+v", test.name, br)
+		}
+		if !reflect.DeepEqual(br.Query, test.query) || !reflect.DeepEqual(br.Form, test.form) || !reflect.DeepEqual(br.Files, test.files) || string(br.JSON) != test.json {
+			t.Errorf("%s: unexpected decoding query=%v form=%v files=%v json=%s", test.name, br.Query, br.Form, br.Files, br.JSON)
+		}
+	}
+
+	// the raw body of the binary request
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/hook/.knut/requests/6/body", nil))
+	if w.Body.String() != "\xff\xfe" {
+		t.Errorf("unexpected raw body %q", w.Body.String())
+	}
+}
+
+func TestBinKeep(t *testing.T) {
+
+	dir := t.TempDir()
+	h := BinHandler(dir, "/", BinOptions{Keep: 2})
+	for _, path := range []string{"/1", "/2", "/3"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", path, strings.NewReader(path)))
+	}
+
+	tests := []struct {
+		name   string
+		remote string
+		code   int
+	}{
+		{"stranger", "192.0.2.1:1234", http.StatusForbidden},
+		{"operator", "127.0.0.1:1234", http.StatusOK},
+	}
+	for _, test := range tests {
+		// a new handler on the same folder sees the kept requests
+		h := BinHandler(dir, "/", BinOptions{Keep: 2, View: RecentOperator})
+		r := httptest.NewRequest("GET", "/.knut/requests", nil)
+		r.RemoteAddr = test.remote
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d, got %d", test.name, test.code, w.Code)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		list := []binRequest{}
+		json.Unmarshal(w.Body.Bytes(), &list)
+		if len(list) != 2 || list[0].URI != "/3" || list[1].URI != "/2" || list[0].Body != "/3" {
+			t.Errorf("%s: expected /3 and /2, got %+v", test.name, list)
+		}
+	}
+
+	// the next request continues the ids
+	h = BinHandler(dir, "/", BinOptions{Keep: 2})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/4", nil))
+	if br := h.(*binHandler).find(4); br == nil || br.URI != "/4" {
+		t.Errorf("expected /4 as #4, got %+v", br)
+	}
+}
+
+func TestBinEvents(t *testing.T) {
+
+	server := httptest.NewServer(BinHandler("", "/", BinOptions{View: RecentAll}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/.knut/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+	events.ReadString('\n') // the comment which opens the stream
+
+	http.Post(server.URL+"/live", "application/json", strings.NewReader(`{"a":1}`))
+
+	lines := []string{}
+	for len(lines) < 2 {
+		line, err := events.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	br := binRequest{}
+	if lines[0] != "event: request" || json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &br) != nil || br.URI != "/live" || string(br.JSON) != `{"a":1}` {
+		t.Errorf("unexpected event %q", lines)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheRule maps requests matching 'Pattern' to a "Cache-Control" value.
+type CacheRule struct {
+	Pattern      string
+	CacheControl string
+}
+
+// CachePolicy is an ordered list of CacheRules, the first matching rule
+// wins.
+type CachePolicy []CacheRule
+
+// ParseCachePolicy parses 'spec', a comma separated list of
+// "glob=directive[:directive...]" rules. a directive is one of
+//
+//	no-cache  - revalidate every time ("private, max-age=0, no-cache")
+//	no-store  - do not store at all
+//	private   - only the browser may cache, no shared caches
+//	immutable - the content never changes while fresh
+//	<age>     - max-age, eg "3600", "90m", "24h" or "365d"
+//
+// a glob without a "/" is matched against the basename of the request
+// path, otherwise against the full request path (relative to the mapping,
+// if the policy is given per mapping). eg:
+//
+//	assets/*=365d:immutable,*.html=no-cache,*=1h
+func ParseCachePolicy(spec string) (CachePolicy, error) {
+
+	policy := CachePolicy{}
+	for _, rule := range strings.Split(spec, ",") {
+		if rule = strings.TrimSpace(rule); rule == "" {
+			continue
+		}
+		pattern, directives, found := strings.Cut(rule, "=")
+		if !found || pattern == "" || directives == "" {
+			return nil, fmt.Errorf("cache rule %q: expected glob=directive", rule)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("cache rule %q: %v", rule, err)
+		}
+		cc, err := cacheControl(strings.Split(directives, ":"))
+		if err != nil {
+			return nil, fmt.Errorf("cache rule %q: %v", rule, err)
+		}
+		policy = append(policy, CacheRule{Pattern: pattern, CacheControl: cc})
+	}
+	return policy, nil
+}
+
+func cacheControl(directives []string) (string, error) {
+
+	var (
+		cc        = []string{}
+		public    = "public"
+		hasMaxAge = false
+	)
+
+	for _, directive := range directives {
+		switch directive {
+		case "no-cache":
+			return "private, max-age=0, no-cache", nil
+		case "no-store":
+			return "no-store", nil
+		case "private":
+			public = "private"
+		case "immutable":
+			cc = append(cc, directive)
+		default:
+			age, err := ParseMaxAge(directive)
+			if err != nil {
+				return "", err
+			}
+			cc = append([]string{"max-age=" + strconv.FormatInt(int64(age.Seconds()), 10)}, cc...)
+			hasMaxAge = true
+		}
+	}
+
+	if !hasMaxAge {
+		return "", fmt.Errorf("missing max-age")
+	}
+	return strings.Join(append([]string{public}, cc...), ", "), nil
+}
+
+// ParseMaxAge accepts plain seconds and what ParseDuration accepts.
+func ParseMaxAge(s string) (time.Duration, error) {
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max-age %q", s)
+	}
+	return d, nil
+}
+
+// Match returns the "Cache-Control" value of the first rule matching
+// 'urlPath', "" if no rule matches.
+func (policy CachePolicy) Match(urlPath string) string {
+	base := path.Base(urlPath)
+	if strings.HasSuffix(urlPath, "/") {
+		base = ""
+	}
+	for _, rule := range policy {
+		name := base
+		if strings.Contains(rule.Pattern, "/") {
+			name = strings.TrimPrefix(urlPath, "/")
+		}
+		if ok, _ := path.Match(rule.Pattern, name); ok {
+			return rule.CacheControl
+		}
+	}
+	return ""
+}
+
+// CachePolicyHandler adds the "Cache-Control" header 'policy' yields
+// for the request path (without 'prefix') to successful (2xx) and "304
+// Not Modified" responses, errors are not cached. a "Cache-Control" set
+// by 'next' wins.
+func CachePolicyHandler(next http.Handler, policy CachePolicy, prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cc := policy.Match(strings.TrimPrefix(r.URL.Path, prefix)); cc != "" {
+			w = &cachePolicyWriter{ResponseWriter: w, cacheControl: cc}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cachePolicyWriter sets "Cache-Control" once the status is known.
+type cachePolicyWriter struct {
+	http.ResponseWriter
+	cacheControl string
+	wroteHeader  bool
+}
+
+func (cw *cachePolicyWriter) WriteHeader(code int) {
+	if !cw.wroteHeader && code >= 200 {
+		cw.wroteHeader = true
+		h := cw.Header()
+		if (code < 300 || code == http.StatusNotModified) && h.Get("Cache-Control") == "" {
+			h.Set("Cache-Control", cw.cacheControl)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *cachePolicyWriter) Write(data []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(data)
+}
+
+// ReadFrom keeps the sendfile path of the wrapped http.ResponseWriter
+// usable for http.ServeContent.
+func (cw *cachePolicyWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if rf, ok := cw.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(writerOnly{cw.ResponseWriter}, r)
+}
+
+func (cw *cachePolicyWriter) Unwrap() http.ResponseWriter { return cw.ResponseWriter }
+
+// FileETagHandler adds a strong "ETag" to responses for the regular files
+// 'resolve' maps the request to. http.ServeContent then handles
+// "If-None-Match" and "If-Range" requests.
+func FileETagHandler(next http.Handler, resolve func(*http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if name := resolve(r); name != "" {
+			if fi, err := os.Stat(name); err == nil && fi.Mode().IsRegular() {
+				w.Header().Set("ETag", fileETag(fi))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// fileETag derives a strong validator from modification time and size
+// of the file, like most webservers do.
+func fileETag(fi os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size())
+}
+
+// checkNotModified evaluates "If-None-Match" and "If-Modified-Since" of
+// 'r' against 'etag' and 'modTime' for handlers which can't use
+// http.ServeContent. if the client's copy is still fresh, a 304 is
+// written and true is returned.
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string, modTime time.Time) bool {
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatches(inm, etag) {
+			return false
+		}
+	} else {
+		ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || modTime.IsZero() || modTime.Truncate(time.Second).After(ims) {
+			return false
+		}
+	}
+
+	h := w.Header()
+	delete(h, "Content-Type")
+	delete(h, "Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches does the weak comparison of If-None-Match, see RFC 9110,
+// section 13.1.2
+func etagMatches(ifNoneMatch, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseCachePolicy(t *testing.T) {
+
+	policy, err := ParseCachePolicy("assets/*=365d:immutable, *.html=no-cache,*.json=no-store,*=90m:private")
+	if err != nil {
+		t.Fatalf("parsing policy: %v", err)
+	}
+
+	tests := []struct{ path, cacheControl string }{
+		{"/assets/app.js", "public, max-age=31536000, immutable"},
+		{"assets/app.js", "public, max-age=31536000, immutable"},
+		{"/index.html", "private, max-age=0, no-cache"},
+		{"/assets/index.html", "public, max-age=31536000, immutable"},
+		{"/api/data.json", "no-store"},
+		{"/img/logo.png", "private, max-age=5400"},
+		{"/", "private, max-age=5400"},
+	}
+
+	for i, test := range tests {
+		cc := policy.Match(test.path)
+		t.Logf("case %d: %q => %q", i, test.path, cc)
+		if cc != test.cacheControl {
+			t.Errorf("case %d: %q expected %q, got %q", i, test.path, test.cacheControl, cc)
+		}
+	}
+
+	for _, invalid := range []string{"*.js", "*.js=", "*.js=immutable", "*.js=1y", "[=1h"} {
+		if _, err := ParseCachePolicy(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+
+	tests := []struct {
+		inm, etag string
+		match     bool
+	}{
+		{`"a"`, `"a"`, true},
+		{`W/"a"`, `"a"`, true},
+		{`"a"`, `W/"a"`, true},
+		{`"b", "a"`, `"a"`, true},
+		{`*`, `"a"`, true},
+		{`"b"`, `"a"`, false},
+		{`"a"`, ``, false},
+	}
+
+	for i, test := range tests {
+		if match := etagMatches(test.inm, test.etag); match != test.match {
+			t.Errorf("case %d: %q vs %q expected %v", i, test.inm, test.etag, test.match)
+		}
+	}
+}
+
+func TestCachePolicyHandler(t *testing.T) {
+
+	policy, _ := ParseCachePolicy("assets/*=365d:immutable")
+	immutable := "public, max-age=31536000, immutable"
+
+	tests := []struct {
+		name         string
+		next         http.HandlerFunc
+		cacheControl string
+	}{
+		{"ok", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }, immutable},
+		{"created", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusCreated) }, immutable},
+		{"not modified", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotModified) }, immutable},
+		{"read from", func(w http.ResponseWriter, r *http.Request) {
+			w.(io.ReaderFrom).ReadFrom(strings.NewReader("ok"))
+		}, immutable},
+		{"not found", http.NotFound, ""},
+		{"error", func(w http.ResponseWriter, r *http.Request) { writeStatus(w, http.StatusInternalServerError) }, ""},
+		{"own", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Write([]byte("ok"))
+		}, "no-store"},
+	}
+
+	for _, test := range tests {
+		h := CachePolicyHandler(test.next, policy, "/static")
+		r := httptest.NewRequest("GET", "/static/assets/app.js", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if cc := w.Header().Get("Cache-Control"); cc != test.cacheControl {
+			t.Errorf("%s: expected %q, got %q", test.name, test.cacheControl, cc)
+		}
+	}
+}
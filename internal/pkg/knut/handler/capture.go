@@ -0,0 +1,164 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Exchange is a captured request and its response. the bodies are cut
+// after the size limit of the capture, their full size is kept.
+type Exchange struct {
+	Started  time.Time
+	Duration time.Duration
+	Remote   string
+
+	Method, URL, Proto string
+	RequestHeader      http.Header
+	RequestBody        []byte
+	RequestSize        int64
+
+	Status         int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+	ResponseSize   int64
+}
+
+// ExchangeSink stores captured exchanges, see NewHARCapture and
+// NewHTTPCapture.
+type ExchangeSink interface {
+	Store(ex *Exchange) error
+}
+
+// CaptureHandler records each request to 'next' and its response in
+// 'sink', at most 'maxBody' bytes of each body (0: no limit). the request
+// body is recorded while 'next' reads it, the part 'next' leaves unread
+// is read afterwards, up to 'maxBody'.
+func CaptureHandler(next http.Handler, sink ExchangeSink, maxBody int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		ex := &Exchange{
+			Started: time.Now(), Remote: r.RemoteAddr,
+			Method: r.Method, URL: scheme + "://" + r.Host + r.RequestURI, Proto: r.Proto,
+			RequestHeader: r.Header.Clone(),
+		}
+
+		body := &captureBody{rc: r.Body, max: maxBody}
+		r.Body = body
+		cw := &captureWriter{ResponseWriter: w, max: maxBody}
+
+		next.ServeHTTP(cw, r)
+
+		if !body.eof { // for the record, 'next' is done
+			if maxBody <= 0 {
+				io.Copy(io.Discard, body)
+			} else if left := maxBody - int64(body.buf.Len()); left > 0 {
+				io.CopyN(io.Discard, body, left)
+			}
+		}
+		ex.Duration = time.Since(ex.Started)
+		ex.RequestBody, ex.RequestSize = body.buf.Bytes(), body.size
+		if r.ContentLength > body.size {
+			ex.RequestSize = r.ContentLength
+		}
+		if cw.code == 0 {
+			cw.code, cw.header = http.StatusOK, w.Header().Clone()
+		}
+		ex.Status, ex.ResponseHeader = cw.code, cw.header
+		ex.ResponseBody, ex.ResponseSize = cw.buf.Bytes(), cw.size
+
+		if err := sink.Store(ex); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: capture %s %s: %v\n", ex.Method, ex.URL, err)
+		}
+	})
+}
+
+// captureBody records the first 'max' bytes read from 'rc'.
+type captureBody struct {
+	rc   io.ReadCloser
+	buf  bytes.Buffer
+	max  int64
+	size int64
+	eof  bool
+}
+
+func (cb *captureBody) Read(p []byte) (int, error) {
+	n, err := cb.rc.Read(p)
+	cb.size += int64(n)
+	if cb.max <= 0 {
+		cb.buf.Write(p[:n])
+	} else if left := cb.max - int64(cb.buf.Len()); left > 0 {
+		cb.buf.Write(p[:min(int64(n), left)])
+	}
+	if err == io.EOF {
+		cb.eof = true
+	}
+	return n, err
+}
+
+func (cb *captureBody) Close() error { return cb.rc.Close() }
+
+// captureWriter records the status, the headers and the first 'max' bytes
+// of the response.
+type captureWriter struct {
+	http.ResponseWriter
+	code   int
+	header http.Header
+	buf    bytes.Buffer
+	max    int64
+	size   int64
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	if cw.code == 0 && code >= 200 {
+		cw.code, cw.header = code, cw.ResponseWriter.Header().Clone()
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *captureWriter) Write(p []byte) (int, error) {
+	if cw.code == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	n, err := cw.ResponseWriter.Write(p)
+	cw.size += int64(n)
+	if cw.max <= 0 {
+		cw.buf.Write(p[:n])
+	} else if left := cw.max - int64(cw.buf.Len()); left > 0 {
+		cw.buf.Write(p[:min(int64(n), left)])
+	}
+	return n, err
+}
+
+// ReadFrom keeps the io.ReaderFrom of the wrapped writer (sendfile for
+// files): only the first 'max' bytes pass Write to be recorded, the rest
+// is handed over as is.
+func (cw *captureWriter) ReadFrom(src io.Reader) (int64, error) {
+	rf, ok := cw.ResponseWriter.(io.ReaderFrom)
+	if !ok || cw.max <= 0 {
+		return io.Copy(struct{ io.Writer }{cw}, src)
+	}
+	left := max(cw.max-int64(cw.buf.Len()), 0)
+	n, err := io.Copy(struct{ io.Writer }{cw}, io.LimitReader(src, left))
+	if err != nil || n < left {
+		return n, err
+	}
+	if cw.code == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	m, err := rf.ReadFrom(src)
+	cw.size += m
+	return n + m, err
+}
+
+func (cw *captureWriter) Unwrap() http.ResponseWriter { return cw.ResponseWriter }
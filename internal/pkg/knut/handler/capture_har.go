@@ -0,0 +1,227 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR is a HTTP Archive 1.2 (http://www.softwareishard.com/blog/har-12-spec/),
+// reduced to what knut captures and replays.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"` // milliseconds
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ClientIPAddress string      `json:"_clientIPAddress,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+	Comment     string         `json:"comment,omitempty"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+	Comment     string         `json:"comment,omitempty"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData carries the request body, "_encoding" is "base64" for
+// binary bodies (not part of the spec, the same as HARContent.Encoding).
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"_encoding,omitempty"`
+}
+
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// Body returns the decoded request body of the entry.
+func (pd *HARPostData) Body() ([]byte, error) {
+	if pd == nil {
+		return nil, nil
+	}
+	if pd.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(pd.Text)
+	}
+	return []byte(pd.Text), nil
+}
+
+// harCapture appends each exchange to a HAR file, which is valid JSON
+// after each exchange.
+type harCapture struct {
+	mu      sync.Mutex
+	file    *os.File
+	entries int
+}
+
+const harTail = "\n]}}\n"
+
+// NewHARCapture creates (or truncates) the HAR file 'name'.
+func NewHARCapture(name, creatorVersion string) (ExchangeSink, error) {
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	head, _ := json.Marshal(HARCreator{Name: "knut", Version: creatorVersion})
+	if _, err := io.WriteString(file, `{"log":{"version":"1.2","creator":`+string(head)+`,"entries":[`+harTail); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &harCapture{file: file}, nil
+}
+
+func (hc *harCapture) Store(ex *Exchange) error {
+
+	data, err := json.Marshal(harEntry(ex))
+	if err != nil {
+		return err
+	}
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	sep := "\n"
+	if hc.entries > 0 {
+		sep = ",\n"
+	}
+	// overwrite the tail, the file stays a complete HAR
+	if _, err := hc.file.Seek(-int64(len(harTail)), io.SeekEnd); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(hc.file, sep+string(data)+harTail); err != nil {
+		return err
+	}
+	hc.entries++
+	return nil
+}
+
+func harEntry(ex *Exchange) HAREntry {
+
+	entry := HAREntry{
+		StartedDateTime: ex.Started,
+		Time:            float64(ex.Duration.Microseconds()) / 1000,
+		Timings:         HARTimings{Send: 0, Wait: float64(ex.Duration.Microseconds()) / 1000, Receive: 0},
+		ClientIPAddress: remoteIP(ex.Remote),
+	}
+
+	req := HARRequest{
+		Method: ex.Method, URL: ex.URL, HTTPVersion: ex.Proto,
+		Cookies: []HARNameValue{}, Headers: harHeaders(ex.RequestHeader), QueryString: []HARNameValue{},
+		HeadersSize: -1, BodySize: ex.RequestSize,
+	}
+	if u, err := url.Parse(ex.URL); err == nil {
+		query := u.Query()
+		for _, key := range slices.Sorted(maps.Keys(query)) {
+			for _, v := range query[key] {
+				req.QueryString = append(req.QueryString, HARNameValue{key, v})
+			}
+		}
+	}
+	if ex.RequestSize > 0 || len(ex.RequestBody) > 0 {
+		text, encoding := harText(ex.RequestBody)
+		req.PostData = &HARPostData{MimeType: ex.RequestHeader.Get("Content-Type"), Text: text, Encoding: encoding}
+		if int64(len(ex.RequestBody)) < ex.RequestSize {
+			req.Comment = "body truncated"
+		}
+	}
+	entry.Request = req
+
+	ctype := ex.ResponseHeader.Get("Content-Type")
+	resp := HARResponse{
+		Status: ex.Status, StatusText: http.StatusText(ex.Status), HTTPVersion: ex.Proto,
+		Cookies: []HARNameValue{}, Headers: harHeaders(ex.ResponseHeader),
+		Content:     HARContent{Size: ex.ResponseSize, MimeType: ctype},
+		RedirectURL: ex.ResponseHeader.Get("Location"), HeadersSize: -1, BodySize: ex.ResponseSize,
+	}
+	resp.Content.Text, resp.Content.Encoding = harText(ex.ResponseBody)
+	if mt, _, _ := mime.ParseMediaType(ctype); ex.ResponseHeader.Get("Content-Encoding") != "" || strings.HasPrefix(mt, "image/") {
+		resp.Content.Text, resp.Content.Encoding = base64.StdEncoding.EncodeToString(ex.ResponseBody), "base64"
+	}
+	if int64(len(ex.ResponseBody)) < ex.ResponseSize {
+		resp.Comment = "body truncated"
+	}
+	entry.Response = resp
+
+	return entry
+}
+
+func harHeaders(header http.Header) []HARNameValue {
+	nvs := []HARNameValue{}
+	for _, key := range slices.Sorted(maps.Keys(header)) {
+		for _, v := range header[key] {
+			nvs = append(nvs, HARNameValue{key, v})
+		}
+	}
+	return nvs
+}
+
+// harText returns 'body' as text, base64 encoded if it is not utf-8.
+func harText(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
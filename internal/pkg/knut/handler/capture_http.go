@@ -0,0 +1,81 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// httpCapture writes each exchange into a file of its own, the request
+// as raw HTTP (usable by the ".http" tooling of editors), the response
+// below "### response" as comments.
+type httpCapture struct {
+	dir string
+	seq atomic.Int64
+}
+
+// NewHTTPCapture writes the exchanges into the folder 'dir'.
+func NewHTTPCapture(dir string) (ExchangeSink, error) {
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return nil, err
+	}
+	return &httpCapture{dir: dir}, nil
+}
+
+func (hc *httpCapture) Store(ex *Exchange) error {
+
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "%s %s %s\n", ex.Method, ex.URL, ex.Proto)
+	writeCapturedHeader(buf, "", ex.RequestHeader)
+	if len(ex.RequestBody) > 0 {
+		buf.WriteString("\n")
+		buf.Write(ex.RequestBody)
+		if !bytes.HasSuffix(ex.RequestBody, []byte("\n")) {
+			buf.WriteString("\n")
+		}
+		if int64(len(ex.RequestBody)) < ex.RequestSize {
+			fmt.Fprintf(buf, "\n# body truncated, %d of %d bytes\n", len(ex.RequestBody), ex.RequestSize)
+		}
+	}
+
+	fmt.Fprintf(buf, "\n### response\n# %s %d %s\n", ex.Proto, ex.Status, http.StatusText(ex.Status))
+	writeCapturedHeader(buf, "# ", ex.ResponseHeader)
+	if len(ex.ResponseBody) > 0 {
+		buf.WriteString("#\n")
+		if utf8.Valid(ex.ResponseBody) && ex.ResponseHeader.Get("Content-Encoding") == "" {
+			scanner := bufio.NewScanner(bytes.NewReader(ex.ResponseBody))
+			scanner.Buffer(nil, len(ex.ResponseBody)+1)
+			for scanner.Scan() {
+				buf.WriteString("# " + scanner.Text() + "\n")
+			}
+		} else {
+			fmt.Fprintf(buf, "# (%d bytes of binary data)\n", len(ex.ResponseBody))
+		}
+		if int64(len(ex.ResponseBody)) < ex.ResponseSize {
+			fmt.Fprintf(buf, "# body truncated, %d of %d bytes\n", len(ex.ResponseBody), ex.ResponseSize)
+		}
+	}
+
+	name := fmt.Sprintf("%s-%06d-%s.http", ex.Started.Format("20060102-150405"), hc.seq.Add(1), strings.ToLower(ex.Method))
+	_, err := storeExclusive(filepath.Join(hc.dir, name), buf)
+	return err
+}
+
+func writeCapturedHeader(buf *bytes.Buffer, prefix string, header http.Header) {
+	for _, key := range slices.Sorted(maps.Keys(header)) {
+		for _, v := range header[key] {
+			buf.WriteString(prefix + key + ": " + v + "\n")
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// echoBody answers with the body it read, or "-" if it reads nothing
+func echoBody(read bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if !read {
+			io.WriteString(w, "-")
+			return
+		}
+		io.Copy(w, r.Body)
+	})
+}
+
+func TestCaptureHAR(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		read        bool
+		body        string
+		maxBody     int64
+		reqText     string
+		respText    string
+		reqComment  string
+		respComment string
+	}{
+		{"read", true, "hello capture", 0, "hello capture", "hello capture", "", ""},
+		{"unread", false, "not read", 0, "not read", "-", "", ""},
+		{"truncated", true, "0123456789", 4, "0123", "0123", "body truncated", "body truncated"},
+	}
+
+	for _, test := range tests {
+		name := filepath.Join(t.TempDir(), "capture.har")
+		sink, err := NewHARCapture(name, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := CaptureHandler(echoBody(test.read), sink, test.maxBody)
+
+		for i := 0; i < 2; i++ {
+			r := httptest.NewRequest("POST", "/echo?a=1", strings.NewReader(test.body))
+			r.Header.Set("X-Test", test.name)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if test.read && w.Body.String() != test.body {
+				t.Errorf("%s: handler saw %q, expected %q", test.name, w.Body.String(), test.body)
+			}
+		}
+
+		data, _ := os.ReadFile(name)
+		har := HAR{}
+		if err := json.Unmarshal(data, &har); err != nil {
+			t.Errorf("%s: invalid HAR: %v\n%s", test.name, err, data)
+			continue
+		}
+		if len(har.Log.Entries) != 2 {
+			t.Errorf("%s: expected 2 entries, got %d", test.name, len(har.Log.Entries))
+			continue
+		}
+		entry := har.Log.Entries[1]
+		body, _ := entry.Request.PostData.Body()
+		if entry.Request.Method != "POST" || entry.Request.URL != "http://example.com/echo?a=1" || string(body) != test.reqText {
+			t.Errorf("%s: unexpected request %+v", test.name, entry.Request)
+		}
+		if entry.Request.BodySize != int64(len(test.body)) || entry.Request.Comment != test.reqComment {
+			t.Errorf("%s: expected request size %d (%q), got %d (%q)", test.name, len(test.body), test.reqComment, entry.Request.BodySize, entry.Request.Comment)
+		}
+		if !bytes.Contains(data, []byte(`{"name":"X-Test","value":"`+test.name+`"}`)) {
+			t.Errorf("%s: request header missing", test.name)
+		}
+		if entry.Response.Status != http.StatusOK || entry.Response.Content.Text != test.respText || entry.Response.Comment != test.respComment {
+			t.Errorf("%s: unexpected response %+v", test.name, entry.Response)
+		}
+	}
+}
+
+func TestCaptureHTTP(t *testing.T) {
+
+	dir := t.TempDir()
+	sink, err := NewHTTPCapture(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := CaptureHandler(echoBody(true), sink, 0)
+	r := httptest.NewRequest("PUT", "/echo", strings.NewReader("line 1\nline 2\n"))
+	r.Header.Set("Content-Type", "text/plain")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*-put.http"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 .http file, got %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	expected := "PUT http://example.com/echo HTTP/1.1\nContent-Type: text/plain\n\nline 1\nline 2\n\n" +
+		"### response\n# HTTP/1.1 200 OK\n# Content-Type: text/plain\n#\n# line 1\n# line 2\n"
+	if string(data) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, data)
+	}
+}
+
+func TestTeeBody(t *testing.T) {
+
+	for _, read := range []bool{true, false} {
+		out := bytes.NewBuffer(nil)
+		h := TeeBodyHandler(echoBody(read), out)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("tee")))
+		if read && w.Body.String() != "tee" {
+			t.Errorf("read=%v: handler saw %q", read, w.Body.String())
+		}
+		if out.String() != "\ntee\n" {
+			t.Errorf("read=%v: dumped %q", read, out.String())
+		}
+	}
+}
+
+// readFromRecorder reports the bytes which bypassed Write
+type readFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom int64
+}
+
+func (rr *readFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	n, err := io.Copy(rr.ResponseRecorder, src)
+	rr.readFrom += n
+	return n, err
+}
+
+func TestCaptureWriterReadFrom(t *testing.T) {
+
+	const body = "knut throws trees"
+	tests := []struct {
+		max      int64
+		recorded string
+		readFrom int64
+	}{
+		{4, "knut", int64(len(body)) - 4},
+		{0, body, 0},
+		{100, body, 0},
+	}
+
+	for _, test := range tests {
+		rr := &readFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+		cw := &captureWriter{ResponseWriter: rr, max: test.max}
+		n, err := io.Copy(cw, struct{ io.Reader }{strings.NewReader(body)}) // no io.WriterTo
+		if err != nil || n != int64(len(body)) || rr.Body.String() != body {
+			t.Errorf("max %d: expected %q, got %d %q (%v)", test.max, body, n, rr.Body.String(), err)
+		}
+		if cw.buf.String() != test.recorded || cw.size != int64(len(body)) || cw.code != http.StatusOK {
+			t.Errorf("max %d: expected %q of %d bytes recorded, got %q of %d (%d)", test.max, test.recorded, len(body), cw.buf.String(), cw.size, cw.code)
+		}
+		if rr.readFrom != test.readFrom {
+			t.Errorf("max %d: expected %d bytes via ReadFrom, got %d", test.max, test.readFrom, rr.readFrom)
+		}
+	}
+}
@@ -9,11 +9,15 @@ import (
 	"io"
 	"net/http"
 	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
 )
 
 type compressPoolEntry interface {
 	Write(data []byte) (int, error)
 	Flush() error
+	Close() error
 	Reset(io.Writer)
 }
 
@@ -41,19 +45,37 @@ func newFlatePool(level int) *compressPool {
 	return cp
 }
 
-func (cp *compressPool) Compress(w http.ResponseWriter, r *http.Request,
-	enc string, handler http.Handler) {
+func newBrotliPool(level int) *compressPool {
+	cp := &compressPool{}
+	cp.Pool = sync.Pool{
+		New: func() any {
+			return brotli.NewWriterLevel(nil, level)
+		},
+	}
+	return cp
+}
+
+func newZstdPool(level zstd.EncoderLevel) *compressPool {
+	cp := &compressPool{}
+	cp.Pool = sync.Pool{
+		New: func() any {
+			// one goroutine per encoder: the pool provides the
+			// concurrency, one encoder serves one response.
+			w, _ := zstd.NewWriter(nil,
+				zstd.WithEncoderLevel(level),
+				zstd.WithEncoderConcurrency(1))
+			return w
+		},
+	}
+	return cp
+}
 
-	compressor := cp.Get().(compressPoolEntry)
-	compressor.Reset(w)
-	defer func() {
-		compressor.Flush()
-		cp.Put(compressor)
-	}()
+func (cp *compressPool) Compress(w http.ResponseWriter, r *http.Request,
+	enc string, minSize int, handler http.Handler) {
 
-	w.Header().Set("Content-Encoding", enc)
-	w = &cWriter{Writer: compressor, ResponseWriter: w}
-	handler.ServeHTTP(w, r)
+	lw := &lazyCompressWriter{ResponseWriter: w, pool: cp, enc: enc, minSize: minSize}
+	defer lw.Close()
+	handler.ServeHTTP(lw, r)
 }
 
 type cWriter struct {
@@ -64,3 +86,15 @@ type cWriter struct {
 func (w *cWriter) WriteHeader(code int)           { w.ResponseWriter.WriteHeader(code) }
 func (w *cWriter) Write(data []byte) (int, error) { return w.Writer.Write(data) }
 func (w *cWriter) Header() http.Header            { return w.ResponseWriter.Header() }
+func (w *cWriter) Unwrap() http.ResponseWriter    { return w.ResponseWriter }
+
+// FlushError pushes the pending compressed data to the client, needed for
+// streamed responses like Server-Sent Events.
+func (w *cWriter) FlushError() error {
+	if f, ok := w.Writer.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
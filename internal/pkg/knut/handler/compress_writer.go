@@ -0,0 +1,237 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type compressState int
+
+const (
+	compressPending compressState = iota
+	compressPassThrough
+	compressActive
+)
+
+// lazyCompressWriter decides about compressing the response once the
+// header is known. these responses pass through untouched:
+//
+//   - responses without a body, partial (206) responses and responses
+//     which already carry a "Content-Encoding" (eg, a precompressed file)
+//   - content types which are already compressed (images, archives, ...)
+//   - bodies smaller than 'minSize'
+//
+// if the handler gives no "Content-Length", up to 'minSize' bytes are
+// buffered to tell. passing through keeps "Content-Length" intact and
+// allows the underlaying http.ResponseWriter to use sendfile.
+type lazyCompressWriter struct {
+	http.ResponseWriter
+	pool       *compressPool
+	enc        string
+	minSize    int
+	compressor compressPoolEntry
+
+	state       compressState
+	code        int
+	buf         []byte
+	wroteHeader bool
+}
+
+func (lw *lazyCompressWriter) WriteHeader(code int) {
+	if lw.wroteHeader {
+		return
+	}
+	lw.wroteHeader, lw.code = true, code
+
+	h := lw.Header()
+	if !compressibleResponse(code, h) {
+		lw.start(false)
+		return
+	}
+	if cl, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64); err == nil {
+		lw.start(cl >= int64(lw.minSize) && h.Get("Content-Type") != "")
+	}
+	// else: pending, decided by what gets written
+}
+
+func (lw *lazyCompressWriter) Write(data []byte) (int, error) {
+	if !lw.wroteHeader {
+		lw.WriteHeader(http.StatusOK)
+	}
+	switch lw.state {
+	case compressPending:
+		lw.buf = append(lw.buf, data...)
+		if len(lw.buf) >= lw.minSize {
+			if err := lw.decide(false); err != nil {
+				return 0, err
+			}
+		}
+		return len(data), nil
+	case compressActive:
+		return lw.compressor.Write(data)
+	}
+	return lw.ResponseWriter.Write(data)
+}
+
+// ReadFrom hands 'r' directly to the underlaying http.ResponseWriter when
+// passing through, which enables sendfile for http.ServeContent.
+func (lw *lazyCompressWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !lw.wroteHeader {
+		lw.WriteHeader(http.StatusOK)
+	}
+	if lw.state == compressPassThrough {
+		return io.Copy(lw.ResponseWriter, r)
+	}
+	return io.Copy(writerOnly{lw}, r)
+}
+
+// decide picks compression or pass through for a pending response, based
+// upon what was buffered so far. only a 'complete' response knows its
+// "Content-Length", a flushed one might go on streaming.
+func (lw *lazyCompressWriter) decide(complete bool) error {
+	h := lw.Header()
+	if h.Get("Content-Type") == "" && len(lw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(lw.buf))
+	}
+	compress := len(lw.buf) >= lw.minSize && compressibleContentType(h.Get("Content-Type"))
+	if !compress && complete {
+		h.Set("Content-Length", strconv.Itoa(len(lw.buf)))
+	}
+	lw.start(compress)
+
+	buf := lw.buf
+	lw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := lw.Write(buf)
+	return err
+}
+
+func (lw *lazyCompressWriter) start(compress bool) {
+	lw.state = compressPassThrough
+	if compress {
+		h := lw.Header()
+		h.Set("Content-Encoding", lw.enc)
+		h.Del("Content-Length")
+		// the compressed bytes differ from what a strong ETag
+		// describes. If-None-Match compares weakly, revalidation
+		// keeps working.
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		lw.compressor = lw.pool.Get().(compressPoolEntry)
+		lw.compressor.Reset(lw.ResponseWriter)
+		lw.state = compressActive
+	}
+	lw.ResponseWriter.WriteHeader(lw.code)
+}
+
+func (lw *lazyCompressWriter) Unwrap() http.ResponseWriter { return lw.ResponseWriter }
+
+func (lw *lazyCompressWriter) FlushError() error {
+	// flushing means "send what you have": a streamed response is
+	// decided right now.
+	if lw.wroteHeader && lw.state == compressPending {
+		if err := lw.decide(false); err != nil {
+			return err
+		}
+	}
+	if lw.compressor != nil {
+		if err := lw.compressor.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(lw.ResponseWriter).Flush()
+}
+
+// Close decides about a still pending response, finishes the compressed
+// stream and returns the compressor to the pool.
+func (lw *lazyCompressWriter) Close() error {
+	if lw.wroteHeader && lw.state == compressPending {
+		if err := lw.decide(true); err != nil {
+			return err
+		}
+	}
+	if lw.compressor == nil {
+		return nil
+	}
+	err := lw.compressor.Close()
+	lw.pool.Put(lw.compressor)
+	lw.compressor = nil
+	return err
+}
+
+// writerOnly hides the ReadFrom method of a writer to avoid the recursion
+// of io.Copy calling ReadFrom calling io.Copy.
+type writerOnly struct{ io.Writer }
+
+// compressibleResponse reports whether a response with 'code' and the
+// header 'h' is a candidate for compression at all.
+func compressibleResponse(code int, h http.Header) bool {
+	switch {
+	case !bodyAllowedForStatus(code), code == http.StatusPartialContent:
+		return false
+	case h.Get("Content-Encoding") != "", h.Get("Content-Range") != "":
+		return false
+	case h.Get("Content-Type") != "":
+		return compressibleContentType(h.Get("Content-Type"))
+	}
+	return true
+}
+
+// incompressibleTypes lists media types which are already compressed,
+// compressing them again just burns cpu cycles.
+var incompressibleTypes = map[string]bool{
+	"application/octet-stream":     true,
+	"application/zip":              true,
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+	"application/x-gtar":           true,
+	"application/x-bzip2":          true,
+	"application/x-xz":             true,
+	"application/zstd":             true,
+	"application/x-7z-compressed":  true,
+	"application/vnd.rar":          true,
+	"application/x-rar-compressed": true,
+	"application/pdf":              true,
+	"font/woff":                    true,
+	"font/woff2":                   true,
+}
+
+func compressibleContentType(ctype string) bool {
+	mtype, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return false
+	}
+	if incompressibleTypes[mtype] {
+		return false
+	}
+	switch {
+	case mtype == "image/svg+xml", mtype == "image/bmp":
+		return true
+	case strings.HasPrefix(mtype, "image/"),
+		strings.HasPrefix(mtype, "video/"),
+		strings.HasPrefix(mtype, "audio/"):
+		return false
+	}
+	return true
+}
+
+// bodyAllowedForStatus reports whether a response with 'code' carries a
+// body, see RFC 7230, section 3.3
+func bodyAllowedForStatus(code int) bool {
+	switch {
+	case code >= 100 && code <= 199:
+		return false
+	case code == http.StatusNoContent, code == http.StatusNotModified:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var errOutsideRoot = errors.New("path escapes the mapped root")
+
+// containedPath maps the slash separated 'rel' to a local filename below
+// 'root'. ".." elements can't climb above 'root', and the deepest existing
+// parent of the result must not be a symlink pointing outside of 'root'.
+// the returned name does not need to exist.
+func containedPath(root, rel string) (string, error) {
+
+	cleaned := path.Clean("/" + rel)
+	if strings.ContainsRune(cleaned, 0) {
+		return "", errOutsideRoot
+	}
+	name := filepath.Join(root, filepath.FromSlash(cleaned))
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	realRoot, _ = filepath.Abs(realRoot)
+
+	// walk up to the deepest existing element and resolve it
+	existing := name
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return "", errOutsideRoot
+		}
+		existing = parent
+	}
+	realExisting, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	realExisting, _ = filepath.Abs(realExisting)
+
+	if realExisting != realRoot &&
+		!strings.HasPrefix(realExisting, realRoot+string(filepath.Separator)) {
+		return "", errOutsideRoot
+	}
+	return name, nil
+}
+
+// isRoot reports if 'name' (as returned by containedPath) is 'root' itself.
+func isRoot(root, name string) bool {
+	return filepath.Clean(root) == filepath.Clean(name)
+}
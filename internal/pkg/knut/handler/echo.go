@@ -0,0 +1,183 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// echoInfo is what knut sees about a request.
+type echoInfo struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Proto  string      `json:"proto"`
+	Host   string      `json:"host"`
+	Header http.Header `json:"headers"`
+	Remote string      `json:"remote"`
+	Local  string      `json:"local,omitempty"`
+	TLS    *echoTLS    `json:"tls,omitempty"`
+}
+
+type echoTLS struct {
+	Version     string   `json:"version"`
+	CipherSuite string   `json:"cipher_suite"`
+	ServerName  string   `json:"server_name,omitempty"` // SNI
+	ALPN        string   `json:"alpn,omitempty"`
+	Resumed     bool     `json:"resumed"`
+	ClientCerts []string `json:"client_certificates,omitempty"` // subjects
+}
+
+// EchoHandler answers with what knut sees about the request: method, URL,
+// headers, protocol, the addresses of both ends and the TLS details. the
+// "Accept" header picks JSON, HTML or plain text (the default).
+func EchoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		info := newEchoInfo(r)
+
+		body := bytes.NewBuffer(nil)
+		accept := r.Header.Get("Accept")
+		switch {
+		case strings.Contains(accept, "application/json"):
+			w.Header().Set("Content-Type", "application/json")
+			data, _ := json.MarshalIndent(info, "", "  ")
+			body.Write(data)
+			body.WriteString("\n")
+		case strings.Contains(accept, "text/html"):
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			echoTmpl.Execute(body, info)
+		default:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			info.writeText(body)
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Vary", "Accept")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+		if r.Method != http.MethodHead {
+			w.Write(body.Bytes())
+		}
+	})
+}
+
+func newEchoInfo(r *http.Request) *echoInfo {
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	url := scheme + "://" + r.Host + r.RequestURI
+	if r.URL.IsAbs() { // the absolute form a proxy might send
+		url = r.RequestURI
+	}
+	info := &echoInfo{
+		Method: r.Method,
+		URL:    url,
+		Proto:  r.Proto,
+		Host:   r.Host,
+		Header: r.Header,
+		Remote: r.RemoteAddr,
+	}
+	if local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		info.Local = local.String()
+	}
+	if cs := r.TLS; cs != nil {
+		info.TLS = &echoTLS{
+			Version:     tls.VersionName(cs.Version),
+			CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+			ServerName:  cs.ServerName,
+			ALPN:        cs.NegotiatedProtocol,
+			Resumed:     cs.DidResume,
+		}
+		for _, cert := range cs.PeerCertificates {
+			info.TLS.ClientCerts = append(info.TLS.ClientCerts, cert.Subject.String())
+		}
+	}
+	return info
+}
+
+// writeText renders 'info' like the request it describes, followed by
+// the connection details.
+func (info *echoInfo) writeText(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "%s %s %s\n", info.Method, info.URL, info.Proto)
+	fmt.Fprintf(buf, "Host: %s\n", info.Host)
+	for _, key := range slices.Sorted(maps.Keys(info.Header)) {
+		for _, v := range info.Header[key] {
+			fmt.Fprintf(buf, "%s: %s\n", key, v)
+		}
+	}
+	fmt.Fprintf(buf, "\nremote: %s\n", info.Remote)
+	if info.Local != "" {
+		fmt.Fprintf(buf, "local:  %s\n", info.Local)
+	}
+	if t := info.TLS; t != nil {
+		fmt.Fprintf(buf, "tls:    %s, %s, resumed: %v\n", t.Version, t.CipherSuite, t.Resumed)
+		fmt.Fprintf(buf, "sni:    %s\n", t.ServerName)
+		fmt.Fprintf(buf, "alpn:   %s\n", t.ALPN)
+		for _, subject := range t.ClientCerts {
+			fmt.Fprintf(buf, "client: %s\n", subject)
+		}
+	}
+}
+
+var echoTmpl = template.Must(template.New("echo").Funcs(template.FuncMap{
+	"sorted": func(h http.Header) []string { return slices.Sorted(maps.Keys(h)) },
+}).Parse(`<!doctype html>
+<html>
+<head>
+	<title>knut - echo</title>
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<style type="text/css">
+	* { font-family: monospace }
+	td { padding: 0.1em 1em 0.1em 0; vertical-align: top }
+	</style>
+</head>
+<body>
+<h1>knut - echo</h1>
+<h2>request</h2>
+<table>
+<tr><td>method</td><td>{{ .Method }}</td></tr>
+<tr><td>url</td><td>{{ .URL }}</td></tr>
+<tr><td>protocol</td><td>{{ .Proto }}</td></tr>
+<tr><td>host</td><td>{{ .Host }}</td></tr>
+</table>
+<h2>headers</h2>
+<table>
+{{- range $key := sorted .Header }}{{ range index $.Header $key }}
+<tr><td>{{ $key }}</td><td>{{ . }}</td></tr>
+{{- end }}{{ end }}
+</table>
+<h2>connection</h2>
+<table>
+<tr><td>remote</td><td>{{ .Remote }}</td></tr>
+{{- if .Local }}
+<tr><td>local</td><td>{{ .Local }}</td></tr>
+{{- end }}
+{{- with .TLS }}
+<tr><td>tls</td><td>{{ .Version }}</td></tr>
+<tr><td>cipher suite</td><td>{{ .CipherSuite }}</td></tr>
+<tr><td>sni</td><td>{{ .ServerName }}</td></tr>
+<tr><td>alpn</td><td>{{ .ALPN }}</td></tr>
+<tr><td>resumed</td><td>{{ .Resumed }}</td></tr>
+{{- range .ClientCerts }}
+<tr><td>client certificate</td><td>{{ . }}</td></tr>
+{{- end }}
+{{- else }}
+<tr><td>tls</td><td>none</td></tr>
+{{- end }}
+</table>
+</body>
+</html>
+`))
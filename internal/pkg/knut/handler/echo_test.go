@@ -0,0 +1,129 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEcho(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		accept string
+		tls    bool
+		ctype  string
+		expect []string
+	}{
+		{"text", "", false, "text/plain; charset=utf-8", []string{"GET http://example.com/echo?a=1 HTTP/1.1\nHost: example.com\n", "X-Test: text\n", "remote: 192.0.2.1:1234\n"}},
+		{"text-tls", "*/*", true, "text/plain; charset=utf-8", []string{"GET https://example.com/echo?a=1", "tls:    TLS 1.3, TLS_AES_128_GCM_SHA256", "sni:    knut.example", "alpn:   h2", "client: CN=client"}},
+		{"html", "text/html,*/*", true, "text/html; charset=utf-8", []string{"<td>X-Test</td><td>html</td>", "<td>sni</td><td>knut.example</td>", "<td>client certificate</td><td>CN=client</td>"}},
+		{"html-plain", "text/html", false, "text/html; charset=utf-8", []string{"<td>tls</td><td>none</td>"}},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/echo?a=1", nil)
+		r.Header.Set("X-Test", test.name)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		if test.tls {
+			r.TLS = &tls.ConnectionState{
+				Version:            tls.VersionTLS13,
+				CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+				ServerName:         "knut.example",
+				NegotiatedProtocol: "h2",
+				PeerCertificates:   []*x509.Certificate{{Subject: pkix.Name{CommonName: "client"}}},
+			}
+		}
+		w := httptest.NewRecorder()
+		EchoHandler().ServeHTTP(w, r)
+		if ctype := w.Header().Get("Content-Type"); ctype != test.ctype {
+			t.Errorf("%s: expected %q, got %q", test.name, test.ctype, ctype)
+		}
+		for _, expect := range test.expect {
+			if !strings.Contains(w.Body.String(), expect) {
+				t.Errorf("%s: %q missing in\n%s", test.name, expect, w.Body.String())
+			}
+		}
+	}
+
+	r := httptest.NewRequest("POST", "https://example.com/echo", nil)
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	EchoHandler().ServeHTTP(w, r)
+	info := echoInfo{}
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("json: %v\n%s", err, w.Body.String())
+	}
+	if info.Method != "POST" || info.URL != "https://example.com/echo" || info.TLS == nil || info.Header.Get("Accept") != "application/json" {
+		t.Errorf("json: unexpected %+v", info)
+	}
+}
+
+func TestEchoClientCert(t *testing.T) {
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "knut-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+
+	// the certificate is asked for, not verified: knut only shows it
+	server := httptest.NewUnstartedServer(EchoHandler())
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		certs  []tls.Certificate
+		expect []string
+	}{
+		{"with-cert", []tls.Certificate{cert}, []string{"CN=knut-client"}},
+		{"without-cert", nil, nil},
+	}
+
+	for _, test := range tests {
+		transport := server.Client().Transport.(*http.Transport).Clone() // a connection of its own
+		transport.TLSClientConfig.Certificates = test.certs
+		client := &http.Client{Transport: transport}
+		req, _ := http.NewRequest("GET", server.URL+"/echo", nil)
+		req.Header.Set("Accept", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		info := echoInfo{}
+		err = json.NewDecoder(resp.Body).Decode(&info)
+		resp.Body.Close()
+		if err != nil || info.TLS == nil {
+			t.Fatalf("%s: expected tls details, got %+v (%v)", test.name, info, err)
+		}
+		if !slices.Equal(info.TLS.ClientCerts, test.expect) {
+			t.Errorf("%s: expected client certificates %q, got %q", test.name, test.expect, info.TLS.ClientCerts)
+		}
+	}
+}
@@ -8,10 +8,20 @@ import (
 func FileOrDirHandler(path, uri string) http.Handler {
 
 	if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
-		return ServeFileHandler(path)
+		resolve := func(*http.Request) string { return path }
+		handler := PrecompressedHandler(ServeFileHandler(path), resolve)
+		return FileETagHandler(handler, resolve)
 	}
 
-	handler := http.FileServer(http.Dir(path))
+	handler := http.FileServer(hideVersionsFS{http.Dir(path)})
+	handler = PrecompressedHandler(handler, DirResolver(path))
+	handler = FileETagHandler(handler, DirResolver(path))
 	handler = http.StripPrefix(uri, handler)
-	return handler
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isVersionsPath(r.URL.Path) {
+			http.NotFound(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
 }
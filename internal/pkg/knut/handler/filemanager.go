@@ -0,0 +1,461 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileManagerHandler serves 'dir' via 'uri' like a directory mapping, but
+// renders directories as a small file manager: create folders, rename,
+// move, delete and upload (drag and drop) files. all operations are
+// POSTed to the directory they apply to:
+//
+//	op=mkdir  name=<new folder>
+//	op=move   from=<entry> to=<target>  (rename, "/"-prefixed targets are
+//	                                     relative to the mapped root)
+//	op=delete name=<entry>
+//	op=upload file=<multipart file>...
+//
+// every name is confined to 'dir'. the operations are noted in the access
+// log. JSON is returned if the client asks for it, otherwise the client
+// is redirected back to the directory.
+//
+// if 'versions' is given, uploads may replace existing files and the prior
+// content of replaced or deleted files is kept as revisions, see
+// VersionsHandler.
+func FileManagerHandler(dir, uri string, versions *VersionStore) http.Handler {
+
+	if dir == "" { // "file://." yields "" after url.Parse()
+		dir = "."
+	}
+
+	prefix := strings.TrimSuffix(uri, "/")
+	files := FileOrDirHandler(dir, uri)
+	fm := &fileManager{root: dir, versions: versions}
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		rel, found := strings.CutPrefix(r.URL.Path, prefix)
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			if !strings.HasSuffix(rel, "/") && rel != "" {
+				files.ServeHTTP(w, r)
+				return
+			}
+			fm.renderDir(w, r, rel)
+		case http.MethodPost:
+			if !sameOrigin(r) {
+				writeStatus(w, http.StatusForbidden)
+				return
+			}
+			fm.handleOp(w, r, rel)
+		default:
+			writeStatus(w, http.StatusMethodNotAllowed)
+		}
+	})
+
+	if versions != nil {
+		handler = VersionsHandler(handler, versions, func(r *http.Request) (string, error) {
+			return containedPath(dir, strings.TrimPrefix(r.URL.Path, prefix))
+		})
+	}
+	return handler
+}
+
+type fileManager struct {
+	root     string
+	versions *VersionStore
+}
+
+type fileManagerEntry struct {
+	Name    string
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+}
+
+func (fm *fileManager) renderDir(w http.ResponseWriter, r *http.Request, rel string) {
+
+	name, err := containedPath(fm.root, rel)
+	if err != nil {
+		writeStatus(w, http.StatusForbidden)
+		return
+	}
+	dirEntries, err := os.ReadDir(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	entries := []fileManagerEntry{}
+	for _, de := range dirEntries {
+		if de.Name() == versionsDir {
+			continue
+		}
+		fi, err := de.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, fileManagerEntry{
+			Name: de.Name(), IsDir: de.IsDir(), Size: fi.Size(), ModTime: fi.ModTime(),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fileManagerTmpl.Execute(w, struct {
+		Path     string
+		IsRoot   bool
+		Versions bool
+		Entries  []fileManagerEntry
+	}{path.Clean("/" + rel), isRoot(fm.root, name), fm.versions != nil, entries})
+}
+
+func (fm *fileManager) handleOp(w http.ResponseWriter, r *http.Request, rel string) {
+
+	dir, err := containedPath(fm.root, rel)
+	if err == nil {
+		if fi, serr := os.Stat(dir); serr != nil || !fi.IsDir() {
+			err = fmt.Errorf("%q is not a directory", rel)
+		}
+	}
+	if err != nil {
+		fm.reply(w, r, http.StatusNotFound, err)
+		return
+	}
+
+	// uploads are streamed, everything else is a small form
+	ctype := r.Header.Get("Content-Type")
+	if strings.HasPrefix(ctype, "multipart/form-data") && r.URL.Query().Get("op") == "upload" {
+		code, err := fm.upload(r, dir)
+		fm.reply(w, r, code, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	if err := r.ParseMultipartForm(64 << 10); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		fm.reply(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var code int
+	switch op := r.FormValue("op"); op {
+	case "mkdir":
+		code, err = fm.mkdir(r, dir, r.FormValue("name"))
+	case "move":
+		code, err = fm.move(r, dir, r.FormValue("from"), r.FormValue("to"))
+	case "delete":
+		code, err = fm.delete(r, dir, r.FormValue("name"))
+	default:
+		code, err = http.StatusBadRequest, fmt.Errorf("unknown op %q", op)
+	}
+	fm.reply(w, r, code, err)
+}
+
+// resolve maps 'name' relative to 'dir' (or, if prefixed with "/",
+// relative to the mapped root) to a local filename below the root. the
+// root itself is only a valid result if 'allowRoot' is set.
+func (fm *fileManager) resolve(dir, name string, allowRoot bool) (string, error) {
+	if name == "" || strings.ContainsRune(name, '\\') {
+		return "", fmt.Errorf("invalid name %q", name)
+	}
+	if !strings.HasPrefix(name, "/") {
+		relDir, _ := filepath.Rel(fm.root, dir)
+		name = path.Join(filepath.ToSlash(relDir), name)
+	}
+	resolved, err := containedPath(fm.root, name)
+	if err == nil && !allowRoot && isRoot(fm.root, resolved) {
+		err = errOutsideRoot
+	}
+	if err == nil && isVersionsPath(fm.relName(resolved)) {
+		err = errOutsideRoot
+	}
+	return resolved, err
+}
+
+func (fm *fileManager) mkdir(r *http.Request, dir, name string) (int, error) {
+	target, err := fm.resolve(dir, name, false)
+	if err != nil {
+		return http.StatusForbidden, err
+	}
+	if err := os.Mkdir(target, 0o777); err != nil {
+		return statusForFSError(err), err
+	}
+	AddLogNote(r, "mkdir %q", fm.relName(target))
+	return http.StatusCreated, nil
+}
+
+func (fm *fileManager) move(r *http.Request, dir, from, to string) (int, error) {
+	src, err := fm.resolve(dir, from, false)
+	if err != nil {
+		return http.StatusForbidden, err
+	}
+	dst, err := fm.resolve(dir, to, true)
+	if err != nil {
+		return http.StatusForbidden, err
+	}
+	if fi, err := os.Stat(dst); err == nil && fi.IsDir() {
+		// moving into an existing folder
+		if dst, err = containedPath(fm.root, path.Join(fm.relName(dst), filepath.Base(src))); err != nil {
+			return http.StatusForbidden, err
+		}
+	}
+	if dst == src || strings.HasPrefix(dst, src+string(filepath.Separator)) {
+		return http.StatusConflict, fmt.Errorf("%q can not be moved into itself", fm.relName(src))
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return http.StatusConflict, fmt.Errorf("%q exists already", fm.relName(dst))
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return statusForFSError(err), err
+	}
+	AddLogNote(r, "move %q to %q", fm.relName(src), fm.relName(dst))
+	return http.StatusOK, nil
+}
+
+func (fm *fileManager) delete(r *http.Request, dir, name string) (int, error) {
+	target, err := fm.resolve(dir, name, false)
+	if err != nil {
+		return http.StatusForbidden, err
+	}
+	if _, err := os.Lstat(target); err != nil {
+		return statusForFSError(err), err
+	}
+	if fm.versions != nil {
+		if err := fm.versions.BeforeRemove(target); err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+	if err := os.RemoveAll(target); err != nil {
+		return statusForFSError(err), err
+	}
+	AddLogNote(r, "delete %q", fm.relName(target))
+	return http.StatusOK, nil
+}
+
+// upload streams all "file" parts of the multipart body into 'dir'.
+// existing files are only replaced if revisions are kept.
+func (fm *fileManager) upload(r *http.Request, dir string) (int, error) {
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return http.StatusCreated, nil
+		}
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		if part.FormName() != "file" || part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		target, err := fm.resolve(dir, filepath.Base(filepath.FromSlash(part.FileName())), false)
+		if err != nil {
+			return http.StatusForbidden, err
+		}
+		n, err := fm.store(r, target, part)
+		part.Close()
+		if err != nil {
+			return statusForFSError(err), err
+		}
+		AddLogNote(r, "upload %q (%d bytes)", fm.relName(target), n)
+	}
+}
+
+// store writes 'src' into 'target'. without revisions, an existing
+// 'target' is an error.
+func (fm *fileManager) store(r *http.Request, target string, src io.Reader) (int64, error) {
+	if fm.versions == nil {
+		return storeExclusive(target, src)
+	}
+	if err := fm.versions.BeforeWrite(target); err != nil {
+		return 0, err
+	}
+	n, err := writeFileAtomic(target, src)
+	if err == nil {
+		err = fm.versions.AfterWrite(target, r.RemoteAddr)
+	}
+	return n, err
+}
+
+// storeExclusive writes 'src' into the new file 'name'. a partially
+// written file is removed.
+func storeExclusive(name string, src io.Reader) (int64, error) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, src)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name)
+	}
+	return n, err
+}
+
+func (fm *fileManager) relName(name string) string {
+	rel, _ := filepath.Rel(fm.root, name)
+	return "/" + filepath.ToSlash(rel)
+}
+
+// reply answers an operation: JSON for scripts, a redirect back to the
+// directory for plain html forms.
+func (fm *fileManager) reply(w http.ResponseWriter, r *http.Request, code int, err error) {
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		result := struct {
+			OK    bool   `json:"ok"`
+			Error string `json:"error,omitempty"`
+		}{OK: err == nil}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(code)
+		fmt.Fprintf(w, "%d: %v\n", code, err)
+		return
+	}
+	http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+}
+
+func statusForFSError(err error) int {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, os.ErrExist):
+		return http.StatusConflict
+	case errors.Is(err, os.ErrPermission):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// sameOrigin rejects cross-site form submissions: a browser which has
+// the -auth credentials cached must not be abused by another site to
+// alter the tree.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" || origin == "null" {
+		return origin == ""
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+var fileManagerTmpl = template.Must(template.New("filemanager").Parse(`<!doctype html>
+<html>
+<head>
+	<title>knut - {{ .Path }}</title>
+	<style type="text/css">
+* { font-family: monospace }
+table { border-collapse: collapse }
+td { padding: 0.1em 1em 0.1em 0 }
+form { display: inline }
+#drop { border: 2px dashed #aaa; padding: 1em; margin: 1em 0 }
+#drop.over { border-color: #333; background: #eee }
+	</style>
+</head>
+<body>
+<h1>knut - {{ .Path }}</h1>
+<table>
+{{- if not .IsRoot }}
+<tr><td><a href="../">../</a></td><td></td><td></td><td></td><td></td></tr>
+{{- end }}
+{{- range .Entries }}
+<tr>
+	<td><a href="{{ .Name }}{{ if .IsDir }}/{{ end }}">{{ .Name }}{{ if .IsDir }}/{{ end }}</a></td>
+	<td>{{ if not .IsDir }}{{ .Size }}{{ end }}</td>
+	<td>{{ .ModTime.Format "2006-01-02 15:04:05" }}</td>
+	<td>
+		{{- if and $.Versions (not .IsDir) }}<a href="{{ .Name }}?versions">versions</a>{{ end -}}
+	</td>
+	<td>
+		<form method="post" data-op="move"><input type="hidden" name="op" value="move"><input type="hidden" name="from" value="{{ .Name }}"><input type="text" name="to" value="{{ .Name }}" size="16"><input type="submit" value="move"></form>
+		<form method="post" data-op="delete"><input type="hidden" name="op" value="delete"><input type="hidden" name="name" value="{{ .Name }}"><input type="submit" value="delete"></form>
+	</td>
+</tr>
+{{- end }}
+</table>
+<p>
+	<form method="post"><input type="hidden" name="op" value="mkdir"><input type="text" name="name" placeholder="new folder"><input type="submit" value="create"></form>
+</p>
+<form method="post" action="?op=upload" enctype="multipart/form-data" id="drop">
+	drop files here or <input type="file" name="file" multiple> <input type="submit" value="upload">
+</form>
+<p id="status"></p>
+<script>
+(function() {
+	var status = document.getElementById("status");
+	function send(body, query) {
+		status.textContent = "working ...";
+		return fetch(location.pathname + (query || ""), {
+			method: "POST", body: body, headers: { "Accept": "application/json" }
+		}).then(function(resp) { return resp.json(); }).then(function(result) {
+			if (!result.ok) { status.textContent = "error: " + result.error; return; }
+			location.reload();
+		}).catch(function(err) { status.textContent = "error: " + err; });
+	}
+	document.querySelectorAll("form[data-op]").forEach(function(form) {
+		form.addEventListener("submit", function(ev) {
+			ev.preventDefault();
+			if (form.dataset.op === "delete" && !confirm("delete " + form.elements.name.value + "?")) { return; }
+			send(new URLSearchParams(new FormData(form)));
+		});
+	});
+	var drop = document.getElementById("drop");
+	drop.addEventListener("dragover", function(ev) { ev.preventDefault(); drop.classList.add("over"); });
+	drop.addEventListener("dragleave", function() { drop.classList.remove("over"); });
+	drop.addEventListener("drop", function(ev) {
+		ev.preventDefault();
+		drop.classList.remove("over");
+		var body = new FormData();
+		Array.prototype.forEach.call(ev.dataTransfer.files, function(f) { body.append("file", f); });
+		send(body, "?op=upload");
+	});
+})();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,106 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileManagerHandler(t *testing.T) {
+
+	dir := t.TempDir()
+	h := FileManagerHandler(dir, "/fm/", nil)
+
+	post := func(path string, form url.Values, hdr ...string) int {
+		r := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Accept", "application/json")
+		for i := 0; i+1 < len(hdr); i += 2 {
+			r.Header.Set(hdr[i], hdr[i+1])
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	upload := func(path, name, content string) int {
+		body := bytes.NewBuffer(nil)
+		mw := multipart.NewWriter(body)
+		fw, _ := mw.CreateFormFile("file", name)
+		fw.Write([]byte(content))
+		mw.Close()
+		r := httptest.NewRequest("POST", path+"?op=upload", body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	steps := []struct {
+		name string
+		code int
+		got  func() int
+	}{
+		{"mkdir", http.StatusCreated, func() int {
+			return post("/fm/", url.Values{"op": {"mkdir"}, "name": {"sub"}})
+		}},
+		{"upload", http.StatusSeeOther, func() int { return upload("/fm/sub/", "a.txt", "knut") }},
+		{"upload-existing", http.StatusConflict, func() int { return upload("/fm/sub/", "a.txt", "knut") }},
+		{"upload-traversal", http.StatusSeeOther, func() int { return upload("/fm/sub/", "../../b.txt", "knut") }},
+		{"rename", http.StatusOK, func() int {
+			return post("/fm/sub/", url.Values{"op": {"move"}, "from": {"a.txt"}, "to": {"c.txt"}})
+		}},
+		{"move-to-root", http.StatusOK, func() int {
+			return post("/fm/sub/", url.Values{"op": {"move"}, "from": {"c.txt"}, "to": {"/"}})
+		}},
+		{"move-outside", http.StatusOK, func() int {
+			return post("/fm/", url.Values{"op": {"move"}, "from": {"c.txt"}, "to": {"../../d.txt"}})
+		}},
+		{"mkdir-nested", http.StatusCreated, func() int {
+			return post("/fm/", url.Values{"op": {"mkdir"}, "name": {"sub/deep"}})
+		}},
+		{"move-into-itself", http.StatusConflict, func() int {
+			return post("/fm/", url.Values{"op": {"move"}, "from": {"sub"}, "to": {"sub"}})
+		}},
+		{"move-into-descendant", http.StatusConflict, func() int {
+			return post("/fm/", url.Values{"op": {"move"}, "from": {"sub"}, "to": {"sub/deep/sub"}})
+		}},
+		{"cross-origin", http.StatusForbidden, func() int {
+			return post("/fm/", url.Values{"op": {"delete"}, "name": {"sub"}}, "Origin", "http://evil.example")
+		}},
+		{"delete", http.StatusOK, func() int {
+			return post("/fm/", url.Values{"op": {"delete"}, "name": {"sub"}})
+		}},
+		{"delete-root", http.StatusForbidden, func() int {
+			return post("/fm/", url.Values{"op": {"delete"}, "name": {"/"}})
+		}},
+	}
+
+	for _, step := range steps {
+		if code := step.got(); code != step.code {
+			t.Errorf("%s: expected %d, got %d", step.name, step.code, code)
+		}
+	}
+
+	// ".." can't climb above the root: "../../b.txt" is stored as "b.txt"
+	// inside "sub" (which got deleted), "../../d.txt" ends up in the root.
+	for _, name := range []string{"d.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %q to exist: %v", name, err)
+		}
+	}
+	for _, name := range []string{"sub", "b.txt", "c.txt", filepath.Join("..", "b.txt"), filepath.Join("..", "d.txt")} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("expected %q to be gone", name)
+		}
+	}
+}
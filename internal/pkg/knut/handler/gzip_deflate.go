@@ -7,27 +7,82 @@ import (
 	"compress/flate"
 	"compress/gzip"
 	"net/http"
-	"strings"
+
+	"github.com/klauspost/compress/zstd"
 )
 
-// CompressHandler requests having Accept-Encoding 'deflate' or 'gzip'
-// taken from https://github.com/gorilla/handlers/blob/master/compress.go
-func CompressHandler(handler http.Handler) http.Handler {
+// CompressHandler compresses the responses of 'handler' with the
+// content-coding the client weighs highest in its Accept-Encoding header.
+// on equal weights "zstd" is preferred over "br", "gzip" and "deflate".
+// bodies smaller than 'minSize' and already compressed content are not
+// compressed, see lazyCompressWriter.
+// initially taken from https://github.com/gorilla/handlers/blob/master/compress.go
+func CompressHandler(handler http.Handler, minSize int) http.Handler {
 
-	gzPool := newGzPool(gzip.DefaultCompression)
-	flatePool := newFlatePool(flate.DefaultCompression)
+	offers := []string{"zstd", "br", "gzip", "deflate"}
+	pools := map[string]*compressPool{
+		"zstd":    newZstdPool(zstd.SpeedDefault),
+		"br":      newBrotliPool(brotliDynamicLevel),
+		"gzip":    newGzPool(gzip.DefaultCompression),
+		"deflate": newFlatePool(flate.DefaultCompression),
+	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
-			switch strings.TrimSpace(enc) {
-			case "deflate":
-				flatePool.Compress(w, r, enc, handler)
-				return
-			case "gzip":
-				gzPool.Compress(w, r, enc, handler)
-				return
-			}
+		addVary(w.Header(), "Accept-Encoding")
+
+		enc, ok := negotiateContentCoding(r.Header.Get("Accept-Encoding"), offers)
+		switch {
+		case !ok:
+			// only a response with an unencoded body is unacceptable
+			nw := &notAcceptableWriter{ResponseWriter: w}
+			handler.ServeHTTP(nw, r)
+		case enc == "":
+			handler.ServeHTTP(w, r)
+		default:
+			pools[enc].Compress(w, r, enc, minSize, handler)
 		}
-		handler.ServeHTTP(w, r)
 	})
 }
+
+// brotliDynamicLevel trades compression ratio for speed: the higher
+// brotli levels are too slow to compress responses on the fly.
+const brotliDynamicLevel = 4
+
+// notAcceptableWriter replaces a response carrying a body without any
+// content-coding by "406 Not Acceptable", for clients which reject
+// "identity". responses without a body (eg, 304) or with a coding of
+// their own pass through.
+type notAcceptableWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	refused     bool
+}
+
+func (nw *notAcceptableWriter) WriteHeader(code int) {
+	if nw.wroteHeader {
+		return
+	}
+	nw.wroteHeader = true
+	h := nw.Header()
+	if bodyAllowedForStatus(code) && h.Get("Content-Encoding") == "" {
+		nw.refused = true
+		for _, key := range []string{"Content-Length", "Content-Type", "Content-Range", "ETag", "Last-Modified"} {
+			h.Del(key)
+		}
+		writeStatus(nw.ResponseWriter, http.StatusNotAcceptable)
+		return
+	}
+	nw.ResponseWriter.WriteHeader(code)
+}
+
+func (nw *notAcceptableWriter) Write(data []byte) (int, error) {
+	if !nw.wroteHeader {
+		nw.WriteHeader(http.StatusOK)
+	}
+	if nw.refused {
+		return len(data), nil
+	}
+	return nw.ResponseWriter.Write(data)
+}
+
+func (nw *notAcceptableWriter) Unwrap() http.ResponseWriter { return nw.ResponseWriter }
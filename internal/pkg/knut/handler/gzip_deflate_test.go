@@ -0,0 +1,210 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompressHandler(t *testing.T) {
+
+	text := strings.Repeat("knut throws trees through windows. ", 100)
+
+	tests := []struct {
+		name     string
+		ctype    string
+		body     string
+		code     int
+		rangeHdr string
+		encoded  bool
+	}{
+		{"text", "text/plain", text, http.StatusOK, "", true},
+		{"sniffed", "", text, http.StatusOK, "", true},
+		{"small", "text/plain", "tiny", http.StatusOK, "", false},
+		{"zip", "application/zip", text, http.StatusOK, "", false},
+		{"jpeg", "image/jpeg", text, http.StatusOK, "", false},
+		{"range", "text/plain", text, http.StatusOK, "bytes=0-9", false},
+		{"not-found", "text/plain", text, http.StatusNotFound, "", true},
+	}
+
+	for _, test := range tests {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if test.ctype != "" {
+				w.Header().Set("Content-Type", test.ctype)
+			}
+			if test.code != http.StatusOK {
+				w.WriteHeader(test.code)
+				io.WriteString(w, test.body)
+				return
+			}
+			http.ServeContent(w, r, "", time.Time{}, strings.NewReader(test.body))
+		})
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		if test.rangeHdr != "" {
+			r.Header.Set("Range", test.rangeHdr)
+		}
+		w := httptest.NewRecorder()
+		CompressHandler(h, 1024).ServeHTTP(w, r)
+
+		encoded := w.Header().Get("Content-Encoding") == "gzip"
+		if encoded != test.encoded {
+			t.Errorf("%s: expected encoded=%v, got %v", test.name, test.encoded, encoded)
+		}
+		if !encoded && w.Header().Get("Content-Length") == "" {
+			t.Errorf("%s: missing Content-Length", test.name)
+		}
+		if encoded && w.Header().Get("Content-Length") != "" {
+			t.Errorf("%s: stale Content-Length %q", test.name, w.Header().Get("Content-Length"))
+		}
+	}
+}
+
+func TestCompressHandlerStreaming(t *testing.T) {
+
+	// a streamed response is decided by its first chunk, which must not
+	// fix the "Content-Length" of what follows.
+	tests := []struct {
+		name  string
+		ctype string
+		chunk string
+		flush bool
+	}{
+		{"flushed-small", "text/event-stream", ": hello\n\n", true},
+		{"sniffed-incompressible", "", "\x1f\x8b\x08" + strings.Repeat("\x00", 2045), false},
+		{"sniffed-incompressible-flushed", "", "\x1f\x8b\x08" + strings.Repeat("\x00", 2045), true},
+		{"compressible-flushed", "text/plain", strings.Repeat("t", 2048), true},
+	}
+
+	for _, test := range tests {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if test.ctype != "" {
+				w.Header().Set("Content-Type", test.ctype)
+			}
+			io.WriteString(w, test.chunk)
+			if test.flush {
+				http.NewResponseController(w).Flush()
+			}
+			io.WriteString(w, test.chunk)
+		})
+		server := httptest.NewServer(CompressHandler(h, 1024))
+
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			server.Close()
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		server.Close()
+		if resp.Header.Get("Content-Encoding") == "gzip" {
+			zr, _ := gzip.NewReader(bytes.NewReader(body))
+			body, _ = io.ReadAll(zr)
+		}
+		if string(body) != test.chunk+test.chunk {
+			t.Errorf("%s: got %d of %d bytes (Content-Length %q)", test.name, len(body), 2*len(test.chunk), resp.Header.Get("Content-Length"))
+		}
+	}
+}
+
+func TestCompressHandlerNotAcceptable(t *testing.T) {
+
+	// "*;q=0" rejects every coding knut does not offer, including identity
+	tests := []struct {
+		name   string
+		code   int
+		enc    string
+		expect int
+	}{
+		{"body", http.StatusOK, "", http.StatusNotAcceptable},
+		{"not-found", http.StatusNotFound, "", http.StatusNotAcceptable},
+		{"not-modified", http.StatusNotModified, "", http.StatusNotModified},
+		{"no-content", http.StatusNoContent, "", http.StatusNoContent},
+		{"encoded", http.StatusOK, "x-custom", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			if test.enc != "" {
+				w.Header().Set("Content-Encoding", test.enc)
+			}
+			w.WriteHeader(test.code)
+			io.WriteString(w, "body")
+		})
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", "*;q=0")
+		w := httptest.NewRecorder()
+		CompressHandler(h, 0).ServeHTTP(w, r)
+		if w.Code != test.expect {
+			t.Errorf("%s: expected %d, got %d", test.name, test.expect, w.Code)
+		}
+		if test.expect == http.StatusNotAcceptable && strings.Contains(w.Body.String(), "body") {
+			t.Errorf("%s: refused body was sent: %q", test.name, w.Body.String())
+		}
+	}
+}
+
+func BenchmarkCompressHandlerLargeFile(b *testing.B) {
+
+	const size = 64 << 20
+
+	dir := b.TempDir()
+	binary := filepath.Join(dir, "large.bin")
+	text := filepath.Join(dir, "large.txt")
+
+	data := make([]byte, size)
+	rand.Read(data)
+	os.WriteFile(binary, data, 0o644)
+	os.WriteFile(text, bytes.Repeat([]byte("knut throws trees through windows.\n"), size/35), 0o644)
+
+	benchmarks := []struct {
+		name     string
+		file     string
+		compress bool
+	}{
+		{"binary/plain", binary, false},
+		{"binary/compress", binary, true},
+		{"text/compress", text, true},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			h := ServeFileHandler(bm.file)
+			if bm.compress {
+				h = CompressHandler(h, 1024)
+			}
+			server := httptest.NewServer(h)
+			defer server.Close()
+
+			fi, _ := os.Stat(bm.file)
+			b.SetBytes(fi.Size())
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				req, _ := http.NewRequest("GET", server.URL, nil)
+				req.Header.Set("Accept-Encoding", "gzip")
+				resp, err := server.Client().Do(req)
+				if err != nil {
+					b.Fatal(err)
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+		})
+	}
+}
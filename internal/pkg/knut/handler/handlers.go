@@ -6,6 +6,9 @@ package handler
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // writeStatus renders the given status code and
@@ -14,3 +17,20 @@ func writeStatus(w http.ResponseWriter, code int) {
 	w.WriteHeader(code)
 	fmt.Fprintf(w, "%d: %s", code, http.StatusText(code))
 }
+
+// ParseDuration accepts time.ParseDuration values and days ("7d"),
+// negative durations are invalid.
+func ParseDuration(s string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(s, "d"); found {
+		n, err := strconv.ParseUint(days, 10, 16)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+
+	tests := []struct {
+		in       string
+		expected time.Duration
+		err      string
+	}{
+		{"7d", 7 * 24 * time.Hour, ""},
+		{"90m", 90 * time.Minute, ""},
+		{"1h30m", 90 * time.Minute, ""},
+		{"0s", 0, ""},
+		{"3600", 0, "invalid duration"},
+		{"-1h", 0, "invalid duration"},
+		{"xd", 0, "invalid duration"},
+		{"soon", 0, "invalid duration"},
+	}
+
+	for _, test := range tests {
+		d, err := ParseDuration(test.in)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q: expected error %q, got %v", test.in, test.err, err)
+			}
+			continue
+		}
+		if err != nil || d != test.expected {
+			t.Errorf("%q: expected %s, got %s (%v)", test.in, test.expected, d, err)
+		}
+	}
+
+	// max-age keeps plain seconds and its own wording
+	if d, err := ParseMaxAge("3600"); err != nil || d != time.Hour {
+		t.Errorf("max-age 3600: got %s (%v)", d, err)
+	}
+	if _, err := ParseMaxAge("soon"); err == nil || !strings.Contains(err.Error(), "max-age") {
+		t.Errorf("max-age soon: unexpected error %v", err)
+	}
+}
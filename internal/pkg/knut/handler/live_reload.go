@@ -0,0 +1,362 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// LiveReloadPath is the uri of the Server-Sent Events endpoint the injected
+// script connects to.
+const LiveReloadPath = "/.knut/live-reload"
+
+const liveReloadScript = `<script>(function() {
+	var es = new EventSource("` + LiveReloadPath + `");
+	es.addEventListener("reload", function() { location.reload(); });
+	es.addEventListener("css", function() {
+		document.querySelectorAll('link[rel="stylesheet"]').forEach(function(l) {
+			var u = new URL(l.href);
+			u.searchParams.set("knut-reload", Date.now());
+			l.href = u.toString();
+		});
+	});
+})();</script>
+`
+
+// LiveReload watches directory trees and tells connected browsers via
+// Server-Sent Events to reload the page (or just the stylesheets, if only
+// .css files changed). the trees are watched via inotify (or what the OS
+// offers instead) and polled where that is not available, eg, when the
+// watch limit is reached.
+type LiveReload struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	clients map[chan liveReloadEvent]struct{}
+}
+
+type liveReloadEvent struct {
+	Kind  string   `json:"-"`
+	Paths []string `json:"paths"`
+}
+
+func NewLiveReload(interval time.Duration) *LiveReload {
+	return &LiveReload{
+		interval: interval,
+		clients:  make(map[chan liveReloadEvent]struct{}),
+	}
+}
+
+// Watch reports changes of the files in 'dir' as being below 'uri'.
+func (lr *LiveReload) Watch(dir, uri string) {
+	report := func(changed []string) {
+		for i := range changed {
+			changed[i] = path.Join(uri, filepath.ToSlash(changed[i]))
+		}
+		lr.broadcast(newLiveReloadEvent(changed))
+	}
+	watcher, err := watchTree(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: live-reload of %q polls every %s: %v\n", dir, lr.interval, err)
+		go lr.poll(dir, report)
+		return
+	}
+	go lr.notify(watcher, dir, report)
+}
+
+// poll compares snapshots of 'dir' every lr.interval.
+func (lr *LiveReload) poll(dir string, report func([]string)) {
+	last := snapshotTree(dir)
+	for range time.Tick(lr.interval) {
+		current := snapshotTree(dir)
+		if changed := diffTreeSnapshots(last, current); len(changed) > 0 {
+			report(changed)
+		}
+		last = current
+	}
+}
+
+// notify collects the events of 'watcher' for lr.interval, a build
+// touching many files triggers only one reload. should the watcher fail,
+// polling takes over.
+func (lr *LiveReload) notify(watcher *fsnotify.Watcher, dir string, report func([]string)) {
+
+	changed := map[string]bool{}
+	var flush <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue // eg, touched by an indexer
+			}
+			names := []string{event.Name}
+			if event.Has(fsnotify.Create) {
+				if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+					// fsnotify is not recursive. what is in the new folder
+					// before it is watched shows up only here.
+					if files, _ := addTree(watcher, event.Name); len(files) > 0 {
+						names = files
+					}
+				}
+			}
+			for _, name := range names {
+				if rel, err := filepath.Rel(dir, name); err == nil {
+					changed[rel] = true
+				}
+			}
+			if flush == nil {
+				flush = time.After(lr.interval)
+			}
+		case <-flush:
+			report(slices.Collect(maps.Keys(changed)))
+			clear(changed)
+			flush = nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "warning: live-reload of %q polls every %s: %v\n", dir, lr.interval, err)
+			watcher.Close()
+			lr.poll(dir, report)
+			return
+		}
+	}
+}
+
+// watchTree watches 'dir' and all folders below.
+func watchTree(dir string) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := addTree(watcher, dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	return watcher, nil
+}
+
+// addTree watches 'dir' and all folders below, the files found are
+// returned.
+func addTree(watcher *fsnotify.Watcher, dir string) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(name)
+		}
+		files = append(files, name)
+		return nil
+	})
+	return files, err
+}
+
+func newLiveReloadEvent(paths []string) liveReloadEvent {
+	event := liveReloadEvent{Kind: "css", Paths: paths}
+	for _, p := range paths {
+		if path.Ext(p) != ".css" {
+			event.Kind = "reload"
+			break
+		}
+	}
+	return event
+}
+
+func (lr *LiveReload) broadcast(event liveReloadEvent) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for c := range lr.clients {
+		select {
+		case c <- event:
+		default: // slow client, it will get the next one
+		}
+	}
+}
+
+// ServeHTTP implements the Server-Sent Events endpoint.
+func (lr *LiveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	rc := http.NewResponseController(w)
+
+	events := make(chan liveReloadEvent, 1)
+	lr.mu.Lock()
+	lr.clients[events] = struct{}{}
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, events)
+		lr.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": knut live-reload\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Kind, data)
+			rc.Flush()
+		}
+	}
+}
+
+// Inject adds the live-reload script to all html pages 'next' renders.
+func (lr *LiveReload) Inject(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		// the page is altered on the fly, partial or conditional
+		// responses of the unaltered file make no sense.
+		r.Header.Del("Range")
+		r.Header.Del("If-Range")
+		r.Header.Del("If-Modified-Since")
+		r.Header.Del("If-None-Match")
+
+		iw := &injectWriter{ResponseWriter: w, head: r.Method == http.MethodHead}
+		next.ServeHTTP(iw, r)
+		iw.finish()
+	})
+}
+
+// injectWriter buffers (not encoded) "text/html" responses to insert the
+// live-reload script before </body>. all other responses pass through.
+type injectWriter struct {
+	http.ResponseWriter
+	buf         *bytes.Buffer
+	code        int
+	wroteHeader bool
+	head        bool // only the header is sent
+}
+
+func (iw *injectWriter) WriteHeader(code int) {
+	if iw.wroteHeader {
+		return
+	}
+	iw.wroteHeader = true
+
+	ctype := iw.Header().Get("Content-Type")
+	encoded := iw.Header().Get("Content-Encoding") != ""
+	if code == http.StatusOK && strings.HasPrefix(ctype, "text/html") && !encoded {
+		iw.code, iw.buf = code, bytes.NewBuffer(nil)
+		return
+	}
+	iw.ResponseWriter.WriteHeader(code)
+}
+
+func (iw *injectWriter) Write(data []byte) (int, error) {
+	if !iw.wroteHeader {
+		if iw.Header().Get("Content-Type") == "" {
+			iw.Header().Set("Content-Type", http.DetectContentType(data))
+		}
+		iw.WriteHeader(http.StatusOK)
+	}
+	if iw.buf != nil {
+		return iw.buf.Write(data)
+	}
+	return iw.ResponseWriter.Write(data)
+}
+
+func (iw *injectWriter) Unwrap() http.ResponseWriter { return iw.ResponseWriter }
+
+func (iw *injectWriter) finish() {
+	if iw.buf == nil {
+		return
+	}
+	iw.Header().Del("ETag") // describes the unaltered page
+	if iw.head && iw.buf.Len() == 0 {
+		// the page was not rendered, it grows by the script
+		if cl, err := strconv.Atoi(iw.Header().Get("Content-Length")); err == nil {
+			iw.Header().Set("Content-Length", strconv.Itoa(cl+len(liveReloadScript)))
+		}
+		iw.ResponseWriter.WriteHeader(iw.code)
+		return
+	}
+	page := injectBeforeBodyEnd(iw.buf.Bytes(), []byte(liveReloadScript))
+	iw.Header().Set("Content-Length", strconv.Itoa(len(page)))
+	iw.ResponseWriter.WriteHeader(iw.code)
+	if !iw.head {
+		iw.ResponseWriter.Write(page)
+	}
+}
+
+// injectBeforeBodyEnd inserts 'snippet' right before the last </body> of
+// 'doc' or appends it, if there is none.
+func injectBeforeBodyEnd(doc, snippet []byte) []byte {
+	i := bytes.LastIndex(bytes.ToLower(doc), []byte("</body>"))
+	if i < 0 {
+		return append(doc, snippet...)
+	}
+	out := make([]byte, 0, len(doc)+len(snippet))
+	out = append(out, doc[:i]...)
+	out = append(out, snippet...)
+	return append(out, doc[i:]...)
+}
+
+type treeEntryState struct {
+	size    int64
+	modTime time.Time
+}
+
+// snapshotTree records size and modification time of all the regular files
+// below 'dir', keyed by their path relative to 'dir'.
+func snapshotTree(dir string) map[string]treeEntryState {
+	snapshot := make(map[string]treeEntryState)
+	filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, name)
+		snapshot[rel] = treeEntryState{size: fi.Size(), modTime: fi.ModTime()}
+		return nil
+	})
+	return snapshot
+}
+
+// diffTreeSnapshots returns the names of all entries added, removed or
+// modified between 'a' and 'b'.
+func diffTreeSnapshots(a, b map[string]treeEntryState) []string {
+	changed := []string{}
+	for name, sb := range b {
+		if sa, exists := a[name]; !exists || sa.size != sb.size || !sa.modTime.Equal(sb.modTime) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range a {
+		if _, exists := b[name]; !exists {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,129 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffTreeSnapshots(t *testing.T) {
+
+	now := time.Now()
+	a := map[string]treeEntryState{
+		"same.txt":    {1, now},
+		"grown.txt":   {1, now},
+		"touched.txt": {1, now},
+		"removed.txt": {1, now},
+	}
+	b := map[string]treeEntryState{
+		"same.txt":    {1, now},
+		"grown.txt":   {2, now},
+		"touched.txt": {1, now.Add(time.Second)},
+		"added.txt":   {1, now},
+	}
+
+	changed := diffTreeSnapshots(a, b)
+	slices.Sort(changed)
+	expected := []string{"added.txt", "grown.txt", "removed.txt", "touched.txt"}
+	if !slices.Equal(changed, expected) {
+		t.Errorf("expected %q, got %q", expected, changed)
+	}
+}
+
+func TestNewLiveReloadEvent(t *testing.T) {
+
+	tests := []struct {
+		paths []string
+		kind  string
+	}{
+		{[]string{"/a.css"}, "css"},
+		{[]string{"/a.css", "/b/c.css"}, "css"},
+		{[]string{"/a.css", "/index.html"}, "reload"},
+		{[]string{"/app.js"}, "reload"},
+	}
+
+	for i, test := range tests {
+		event := newLiveReloadEvent(test.paths)
+		if event.Kind != test.kind {
+			t.Errorf("case %d: %q expected %q, got %q", i, test.paths, test.kind, event.Kind)
+		}
+	}
+}
+
+func TestLiveReloadWatch(t *testing.T) {
+
+	dir := t.TempDir()
+	lr := NewLiveReload(20 * time.Millisecond)
+	events := make(chan liveReloadEvent, 4)
+	lr.clients[events] = struct{}{}
+	lr.Watch(dir, "/site")
+
+	tests := []struct {
+		name     string
+		change   func() error
+		expected string
+	}{
+		{"file", func() error { return os.WriteFile(filepath.Join(dir, "a.css"), []byte("a"), 0o644) }, "/site/a.css"},
+		{"new folder", func() error { return os.Mkdir(filepath.Join(dir, "sub"), 0o755) }, "/site/sub"},
+		{"file in new folder", func() error { return os.WriteFile(filepath.Join(dir, "sub", "b.html"), []byte("b"), 0o644) }, "/site/sub/b.html"},
+		{"folder with a file", func() error {
+			// created elsewhere, the file exists before the folder is watched
+			other := filepath.Join(filepath.Dir(dir), filepath.Base(dir)+"-other")
+			if err := os.MkdirAll(filepath.Join(other, "deep"), 0o755); err != nil {
+				return err
+			}
+			defer os.RemoveAll(other)
+			if err := os.WriteFile(filepath.Join(other, "deep", "c.html"), []byte("c"), 0o644); err != nil {
+				return err
+			}
+			return os.Rename(other, filepath.Join(dir, "moved"))
+		}, "/site/moved/deep/c.html"},
+	}
+
+	for _, test := range tests {
+		if err := test.change(); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case event := <-events:
+			if !slices.Contains(event.Paths, test.expected) {
+				t.Errorf("%s: expected %q, got %q", test.name, test.expected, event.Paths)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("%s: no event", test.name)
+		}
+	}
+}
+
+func TestLiveReloadInjectHead(t *testing.T) {
+
+	page := "<html><body>hello</body></html>"
+	h := NewLiveReload(time.Second).Inject(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		http.ServeContent(w, r, "index.html", time.Time{}, strings.NewReader(page))
+	}))
+
+	get := httptest.NewRecorder()
+	h.ServeHTTP(get, httptest.NewRequest("GET", "/", nil))
+	head := httptest.NewRecorder()
+	h.ServeHTTP(head, httptest.NewRequest("HEAD", "/", nil))
+
+	if !strings.Contains(get.Body.String(), LiveReloadPath) {
+		t.Errorf("GET: script missing in %q", get.Body.String())
+	}
+	if head.Body.Len() != 0 {
+		t.Errorf("HEAD: unexpected body %q", head.Body.String())
+	}
+	if cl := head.Header().Get("Content-Length"); cl != strconv.Itoa(get.Body.Len()) {
+		t.Errorf("HEAD: expected Content-Length %d, got %q", get.Body.Len(), cl)
+	}
+}
@@ -4,6 +4,7 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,21 +18,37 @@ func LogRequestHandler(handler http.Handler, logWriter io.Writer) http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var sc = statusCodeCapture{w: w}
-		handler.ServeHTTP(&sc, r)
+		var notes = []string{}
+		handler.ServeHTTP(&sc, r.WithContext(context.WithValue(r.Context(), logNotesKey{}, &notes)))
 		if sc.code == 0 {
 			sc.code = 200
 		}
 		portSep := strings.LastIndex(r.RemoteAddr, ":")
-		fmt.Fprintf(logWriter, "%s\t%s\t%d\t%s\t%s%s\n",
+		fmt.Fprintf(logWriter, "%s\t%s\t%d\t%s\t%s%s",
 			time.Now().Format(time.RFC3339),
 			r.RemoteAddr[:portSep],
 			sc.code,
 			r.Method,
 			r.Host,
 			r.RequestURI)
+		if len(notes) > 0 {
+			fmt.Fprintf(logWriter, "\t%s", strings.Join(notes, "; "))
+		}
+		fmt.Fprintln(logWriter)
 	})
 }
 
+type logNotesKey struct{}
+
+// AddLogNote attaches a note to the access log line of 'r', eg what an
+// operation altering a tree did. without LogRequestHandler the note is
+// dropped.
+func AddLogNote(r *http.Request, format string, a ...any) {
+	if notes, ok := r.Context().Value(logNotesKey{}).(*[]string); ok {
+		*notes = append(*notes, fmt.Sprintf(format, a...))
+	}
+}
+
 type statusCodeCapture struct {
 	w    http.ResponseWriter
 	code int
@@ -40,3 +57,13 @@ type statusCodeCapture struct {
 func (sc *statusCodeCapture) Header() http.Header            { return sc.w.Header() }
 func (sc *statusCodeCapture) Write(data []byte) (int, error) { return sc.w.Write(data) }
 func (sc *statusCodeCapture) WriteHeader(code int)           { sc.code = code; sc.w.WriteHeader(code) }
+func (sc *statusCodeCapture) Unwrap() http.ResponseWriter    { return sc.w }
+
+// ReadFrom keeps the sendfile path of the wrapped http.ResponseWriter
+// usable for http.ServeContent.
+func (sc *statusCodeCapture) ReadFrom(r io.Reader) (int64, error) {
+	if sc.code == 0 {
+		sc.code = http.StatusOK
+	}
+	return io.Copy(sc.w, r)
+}
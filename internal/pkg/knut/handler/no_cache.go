@@ -1,14 +0,0 @@
-// Copyright 2025 Mathias Gumz. All rights reserved. Use of this source code
-// is governed by a BSD-style license that can be found in the LICENSE file.
-
-package handler
-
-import "net/http"
-
-// noCacheHandler adds a 'nocaching, please' hint to the response header
-func NoCacheHandler(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Cache-Control", "private, max-age=0, no-cache")
-		next.ServeHTTP(w, r)
-	})
-}
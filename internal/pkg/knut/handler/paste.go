@@ -0,0 +1,439 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const pasteIDChars = "23456789abcdefghjkmnpqrstuvwxyz"
+
+var errPasteExpired = errors.New("paste expired")
+
+// PasteOptions configure PasteHandler
+type PasteOptions struct {
+	MaxSize int64         // per paste, 0: no limit
+	Expire  time.Duration // default and longest lifetime of a paste, 0: forever
+	List    string        // who sees the listing, RecentOff if empty
+}
+
+// paste is kept as "dir/<id>.json" next to its text "dir/<id>.txt".
+type paste struct {
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires,omitempty"`
+	Burn    bool      `json:"burn,omitempty"` // removed after the first read
+	Lang    string    `json:"lang,omitempty"`
+	Size    int64     `json:"size"`
+	Remote  string    `json:"remote"`
+}
+
+// PasteHandler is a pastebin below 'uri': text POSTed to 'uri' (as the
+// field "text" of a form or as the raw body) is stored in 'dir' under a
+// short id, the reply carries the URL of the paste. "uri/<id>" shows the
+// paste, highlighted for the language "lang", "uri/<id>?raw" as plain
+// text. a paste might "expire" earlier than opts.Expire and be read only
+// once ("burn"); both are form fields or query parameters. GET 'uri'
+// renders a form and, for opts.List, the stored pastes.
+func PasteHandler(dir, uri string, opts PasteOptions) http.Handler {
+	if dir == "" {
+		dir = "."
+	}
+	os.MkdirAll(dir, 0o777)
+	return &pasteHandler{dir: dir, uri: strings.TrimSuffix(uri, "/"), opts: opts}
+}
+
+type pasteHandler struct {
+	dir, uri string
+	opts     PasteOptions
+
+	mu sync.Mutex // serializes reading and burning
+}
+
+func (ph *pasteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, ph.uri), "/")
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			ph.index(w, r)
+		case http.MethodPost:
+			ph.create(w, r)
+		default:
+			writeStatus(w, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if !validPasteID(id) {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		ph.show(w, r, id)
+	case http.MethodDelete, http.MethodPost:
+		// the listing deletes via POST, forms know no DELETE
+		if !showRecent(ph.opts.List, r) || !sameOrigin(r) {
+			writeStatus(w, http.StatusForbidden)
+			return
+		}
+		if _, err := ph.load(id); err != nil {
+			ph.notFound(w, err)
+			return
+		}
+		ph.remove(id)
+		AddLogNote(r, "paste delete %s", id)
+		if r.Method == http.MethodPost {
+			http.Redirect(w, r, ph.uri+"/", http.StatusSeeOther)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeStatus(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (ph *pasteHandler) create(w http.ResponseWriter, r *http.Request) {
+
+	ph.sweep()
+
+	if ph.opts.MaxSize > 0 {
+		if r.ContentLength > ph.opts.MaxSize+64<<10 { // room for the other form fields
+			writeStatus(w, http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, ph.opts.MaxSize+64<<10)
+	}
+
+	text, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeStatus(w, statusForUploadError(err))
+		return
+	}
+
+	// "curl --data-binary" sends any body as url-encoded form, a form is
+	// only assumed if it carries "text".
+	r.Body = io.NopCloser(bytes.NewReader(text))
+	isForm := false
+	switch ctype := r.Header.Get("Content-Type"); {
+	case strings.HasPrefix(ctype, "multipart/form-data"):
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		isForm = true
+	case strings.HasPrefix(ctype, "application/x-www-form-urlencoded"):
+		values, err := url.ParseQuery(string(text))
+		isForm = err == nil && values.Has("text")
+	}
+	if isForm {
+		text = []byte(strings.ReplaceAll(r.FormValue("text"), "\r\n", "\n"))
+	} else {
+		r.Body = http.NoBody // options come from the query only
+	}
+	if len(text) == 0 {
+		http.Error(w, "empty paste", http.StatusBadRequest)
+		return
+	}
+	if ph.opts.MaxSize > 0 && int64(len(text)) > ph.opts.MaxSize {
+		writeStatus(w, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	p := paste{Created: time.Now().UTC(), Size: int64(len(text)), Remote: remoteIP(r.RemoteAddr)}
+	p.Burn = r.FormValue("burn") != "" || r.URL.Query().Has("burn")
+	if lang := r.FormValue("lang"); lang != "" {
+		if _, ok := pasteLanguages[lang]; ok {
+			p.Lang = lang
+		}
+	}
+	expire := ph.opts.Expire
+	if v := r.FormValue("expire"); v != "" {
+		d, err := ParseDuration(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if d > 0 && (expire <= 0 || d < expire) {
+			expire = d
+		}
+	}
+	if expire > 0 {
+		p.Expires = p.Created.Add(expire)
+	}
+
+	id, err := ph.store(text)
+	if err == nil {
+		err = ph.save(id, p)
+	}
+	if err != nil {
+		ph.remove(id)
+		http.Error(w, err.Error(), statusForFSError(err))
+		return
+	}
+	AddLogNote(r, "paste %s (%d bytes)", id, p.Size)
+
+	location := ph.uri + "/" + id
+	if isForm && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, r, location, http.StatusSeeOther)
+		return
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	w.Header().Set("Location", location)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	io.WriteString(w, scheme+"://"+r.Host+location+"\n")
+}
+
+// store writes 'text' under a new id.
+func (ph *pasteHandler) store(text []byte) (string, error) {
+	for {
+		rnd := make([]byte, 6)
+		rand.Read(rnd)
+		for i := range rnd {
+			rnd[i] = pasteIDChars[int(rnd[i])%len(pasteIDChars)]
+		}
+		id := string(rnd)
+		_, err := storeExclusive(ph.textName(id), bytes.NewReader(text))
+		if !errors.Is(err, os.ErrExist) {
+			return id, err
+		}
+	}
+}
+
+func (ph *pasteHandler) show(w http.ResponseWriter, r *http.Request, id string) {
+
+	ph.mu.Lock()
+	p, err := ph.load(id)
+	var text []byte
+	if err == nil {
+		text, err = os.ReadFile(ph.textName(id))
+	}
+	burn := err == nil && p.Burn && r.Method != http.MethodHead
+	if burn {
+		ph.remove(id)
+	}
+	ph.mu.Unlock()
+
+	if err != nil {
+		ph.notFound(w, err)
+		return
+	}
+	if burn {
+		AddLogNote(r, "paste %s burned", id)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	body := bytes.NewBuffer(nil)
+	if r.URL.Query().Has("raw") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		body.Write(text)
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		pasteTmpl.Execute(body, pastePage{ID: id, URI: ph.uri, paste: p, Text: highlight(string(text), p.Lang), Burned: burn})
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	if r.Method != http.MethodHead {
+		w.Write(body.Bytes())
+	}
+}
+
+func (ph *pasteHandler) index(w http.ResponseWriter, r *http.Request) {
+
+	page := pasteIndexPage{URI: ph.uri, Languages: pasteLanguageNames(), MaxSize: ph.opts.MaxSize}
+	if page.ShowList = showRecent(ph.opts.List, r); page.ShowList {
+		ph.sweep()
+		infos, _ := filepath.Glob(filepath.Join(ph.dir, "*.json"))
+		for _, info := range infos {
+			id := strings.TrimSuffix(filepath.Base(info), ".json")
+			if !validPasteID(id) {
+				continue
+			}
+			if p, err := ph.load(id); err == nil {
+				page.Pastes = append(page.Pastes, pasteEntry{ID: id, paste: p})
+			}
+		}
+		sort.Slice(page.Pastes, func(i, j int) bool { return page.Pastes[i].Created.After(page.Pastes[j].Created) })
+	}
+
+	body := bytes.NewBuffer(nil)
+	pasteIndexTmpl.Execute(body, page)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	if r.Method != http.MethodHead {
+		w.Write(body.Bytes())
+	}
+}
+
+// load reads the description of paste 'id', expired pastes are removed.
+func (ph *pasteHandler) load(id string) (paste, error) {
+	p := paste{}
+	data, err := os.ReadFile(ph.infoName(id))
+	if err == nil {
+		err = json.Unmarshal(data, &p)
+	}
+	if err != nil {
+		return p, err
+	}
+	if !p.Expires.IsZero() && time.Now().After(p.Expires) {
+		ph.remove(id)
+		return p, errPasteExpired
+	}
+	return p, nil
+}
+
+func (ph *pasteHandler) save(id string, p paste) error {
+	data, _ := json.Marshal(p)
+	_, err := writeFileAtomic(ph.infoName(id), bytes.NewReader(data))
+	return err
+}
+
+func (ph *pasteHandler) remove(id string) {
+	os.Remove(ph.textName(id))
+	os.Remove(ph.infoName(id))
+}
+
+// sweep removes the expired pastes.
+func (ph *pasteHandler) sweep() {
+	infos, _ := filepath.Glob(filepath.Join(ph.dir, "*.json"))
+	for _, info := range infos {
+		if id := strings.TrimSuffix(filepath.Base(info), ".json"); validPasteID(id) {
+			ph.load(id)
+		}
+	}
+}
+
+func (ph *pasteHandler) notFound(w http.ResponseWriter, err error) {
+	if errors.Is(err, errPasteExpired) {
+		writeStatus(w, http.StatusGone)
+		return
+	}
+	writeStatus(w, http.StatusNotFound)
+}
+
+func (ph *pasteHandler) textName(id string) string {
+	return filepath.Join(ph.dir, id+".txt")
+}
+
+func (ph *pasteHandler) infoName(id string) string {
+	return filepath.Join(ph.dir, id+".json")
+}
+
+func validPasteID(id string) bool {
+	if len(id) != 6 {
+		return false
+	}
+	for i := range id {
+		if strings.IndexByte(pasteIDChars, id[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+type pastePage struct {
+	ID, URI string
+	paste
+	Text   template.HTML
+	Burned bool
+}
+
+type pasteEntry struct {
+	ID string
+	paste
+}
+
+type pasteIndexPage struct {
+	URI       string
+	Languages []string
+	MaxSize   int64
+	ShowList  bool
+	Pastes    []pasteEntry
+}
+
+const pasteStyle = `
+* { font-family: monospace }
+td { padding: 0.1em 1em 0.1em 0 }
+textarea { width: 100%; height: 20em }
+pre { background: #f6f6f6; padding: 1em; overflow-x: auto }
+pre .c { color: #888 } pre .s { color: #070 } pre .n { color: #a50 } pre .k { color: #00a; font-weight: bold }
+.note { color: #b00 }`
+
+var pasteTmpl = template.Must(template.New("paste").Parse(`<!doctype html>
+<html>
+<head>
+	<title>knut - paste {{ .ID }}</title>
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<style type="text/css">` + pasteStyle + `</style>
+</head>
+<body>
+<p><a href="{{ .URI }}/">new paste</a>
+{{- if not .Burned }} - <a href="{{ .URI }}/{{ .ID }}?raw">raw</a>{{ end }}
+- {{ .Size }} bytes{{ if .Lang }}, {{ .Lang }}{{ end }}
+{{- if not .Expires.IsZero }}, expires {{ .Expires.Format "2006-01-02 15:04 MST" }}{{ end }}</p>
+{{- if .Burned }}
+<p class="note">this paste was removed now that it has been read.</p>
+{{- end }}
+<pre>{{ .Text }}</pre>
+</body>
+</html>
+`))
+
+var pasteIndexTmpl = template.Must(template.New("pastes").Parse(`<!doctype html>
+<html>
+<head>
+	<title>knut - paste</title>
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<style type="text/css">` + pasteStyle + `</style>
+</head>
+<body>
+<h1>knut - paste</h1>
+<form method="post">
+	<textarea name="text" required></textarea>
+	<div>
+		<select name="lang"><option value="">plain text</option>
+		{{- range .Languages }}<option>{{ . }}</option>{{ end }}</select>
+		<select name="expire"><option value="">default expiry</option>
+		<option value="10m">10 minutes</option><option value="1h">1 hour</option>
+		<option value="1d">1 day</option><option value="7d">7 days</option></select>
+		<label><input type="checkbox" name="burn" value="1"> burn after reading</label>
+		<input type="submit" value="Paste">
+		{{- if .MaxSize }} (at most {{ .MaxSize }} bytes){{ end }}
+	</div>
+</form>
+{{- if .ShowList }}
+<h2>pastes</h2>
+<table>
+{{- range .Pastes }}
+<tr><td><a href="{{ $.URI }}/{{ .ID }}">{{ .ID }}</a></td><td>{{ .Created.Format "2006-01-02 15:04" }}</td>
+<td>{{ .Size }}</td><td>{{ .Lang }}</td><td>{{ .Remote }}</td>
+<td>{{ if not .Expires.IsZero }}expires {{ .Expires.Format "2006-01-02 15:04" }}{{ end }}</td>
+<td>{{ if .Burn }}burn after reading{{ end }}</td>
+<td><form method="post" action="{{ $.URI }}/{{ .ID }}"><input type="submit" value="delete"></form></td></tr>
+{{- else }}
+<tr><td>nothing yet</td></tr>
+{{- end }}
+</table>
+{{- end }}
+</body>
+</html>
+`))
@@ -0,0 +1,153 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"html"
+	"html/template"
+	"slices"
+	"strings"
+)
+
+// pasteLanguage describes just enough of a language to color its
+// comments, strings, numbers and keywords.
+type pasteLanguage struct {
+	keywords     []string
+	lineComment  []string
+	blockComment [2]string
+	quotes       string
+}
+
+var pasteLanguages = map[string]pasteLanguage{
+	"go": {
+		keywords: strings.Fields(`break case chan const continue default defer else fallthrough for func go goto if
+			import interface map package range return select struct switch type var nil true false`),
+		lineComment: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: "\"'`",
+	},
+	"c": {
+		keywords: strings.Fields(`auto break case char const continue default do double else enum extern float for goto
+			if int long register return short signed sizeof static struct switch typedef union unsigned void volatile while
+			class namespace template public private protected new delete true false nullptr`),
+		lineComment: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: "\"'",
+	},
+	"js": {
+		keywords: strings.Fields(`async await break case catch class const continue default delete do else export extends
+			finally for function if import in instanceof let new return switch this throw try typeof var void while yield
+			null undefined true false`),
+		lineComment: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: "\"'`",
+	},
+	"rust": {
+		keywords: strings.Fields(`as async await break const continue crate else enum extern fn for if impl in let loop
+			match mod move mut pub ref return self Self static struct super trait type unsafe use where while true false`),
+		lineComment: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: "\"",
+	},
+	"python": {
+		keywords: strings.Fields(`and as assert async await break class continue def del elif else except finally for
+			from global if import in is lambda nonlocal not or pass raise return try while with yield None True False`),
+		lineComment: []string{"#"}, quotes: "\"'",
+	},
+	"sh": {
+		keywords: strings.Fields(`case do done elif else esac fi for function if in local return then until while
+			export set unset shift exit`),
+		lineComment: []string{"#"}, quotes: "\"'",
+	},
+	"json": {keywords: []string{"true", "false", "null"}, quotes: "\""},
+	"yaml": {keywords: []string{"true", "false", "null", "yes", "no"}, lineComment: []string{"#"}, quotes: "\"'"},
+}
+
+// pasteLanguageNames lists the languages highlight knows, sorted.
+func pasteLanguageNames() []string {
+	names := []string{}
+	for name := range pasteLanguages {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// highlight returns 'src' as escaped HTML, the tokens of 'lang' wrapped in
+// <span class="c|s|n|k">. unknown languages are only escaped.
+func highlight(src, lang string) template.HTML {
+
+	l, ok := pasteLanguages[lang]
+	if !ok {
+		return template.HTML(html.EscapeString(src))
+	}
+
+	out := strings.Builder{}
+	span := func(class, token string) {
+		out.WriteString(`<span class="` + class + `">` + html.EscapeString(token) + `</span>`)
+	}
+	isIdent := func(c byte, first bool) bool {
+		return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+	}
+
+	for i := 0; i < len(src); {
+		rest := src[i:]
+		if start := l.blockComment[0]; start != "" && strings.HasPrefix(rest, start) {
+			end := strings.Index(rest[len(start):], l.blockComment[1])
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += len(start) + len(l.blockComment[1])
+			}
+			span("c", rest[:end])
+			i += end
+			continue
+		}
+		if slices.ContainsFunc(l.lineComment, func(p string) bool { return strings.HasPrefix(rest, p) }) {
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			span("c", rest[:end])
+			i += end
+			continue
+		}
+
+		c := src[i]
+		switch {
+		case strings.IndexByte(l.quotes, c) >= 0:
+			end := 1
+			for end < len(rest) && rest[end] != c {
+				if rest[end] == '\\' && c != '`' {
+					end++
+				} else if rest[end] == '\n' && c != '`' {
+					break
+				}
+				end++
+			}
+			end = min(end+1, len(rest))
+			span("s", rest[:end])
+			i += end
+		case c >= '0' && c <= '9':
+			end := 1
+			for end < len(rest) && (isIdent(rest[end], false) || rest[end] == '.') {
+				end++
+			}
+			span("n", rest[:end])
+			i += end
+		case isIdent(c, true):
+			end := 1
+			for end < len(rest) && isIdent(rest[end], false) {
+				end++
+			}
+			if word := rest[:end]; slices.Contains(l.keywords, word) {
+				span("k", word)
+			} else {
+				out.WriteString(html.EscapeString(word))
+			}
+			i += end
+		default:
+			end := 1
+			for end < len(rest) && !isIdent(rest[end], true) && !(rest[end] >= '0' && rest[end] <= '9') &&
+				strings.IndexByte(l.quotes, rest[end]) < 0 && rest[end] != '/' && rest[end] != '#' {
+				end++
+			}
+			out.WriteString(html.EscapeString(rest[:end]))
+			i += end
+		}
+	}
+	return template.HTML(out.String())
+}
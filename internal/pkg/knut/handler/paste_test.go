@@ -0,0 +1,128 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPaste(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		query, form string // form is sent url-encoded, otherwise "text" is the raw body
+		text        string
+		code        int
+		reads       []int // status of the following GETs of "?raw"
+	}{
+		{"raw", "", "", "hello knut", http.StatusCreated, []int{http.StatusOK, http.StatusOK}},
+		{"form", "", "text=hello+form&lang=go", "hello form", http.StatusCreated, []int{http.StatusOK}},
+		{"burn", "?burn", "", "secret", http.StatusCreated, []int{http.StatusOK, http.StatusNotFound}},
+		{"burn-form", "", "text=secret&burn=1", "secret", http.StatusCreated, []int{http.StatusOK, http.StatusNotFound}},
+		{"expired", "?expire=1ns", "", "gone", http.StatusCreated, []int{http.StatusGone, http.StatusNotFound}},
+		{"empty", "", "", "", http.StatusBadRequest, nil},
+		{"too-large", "", "", strings.Repeat("x", 65), http.StatusRequestEntityTooLarge, nil},
+		{"bad-expire", "?expire=soon", "", "x", http.StatusBadRequest, nil},
+	}
+
+	for _, test := range tests {
+		h := PasteHandler(t.TempDir(), "/p", PasteOptions{MaxSize: 64, Expire: time.Hour})
+
+		var r *http.Request
+		if test.form != "" {
+			r = httptest.NewRequest("POST", "/p"+test.query, strings.NewReader(test.form))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			r = httptest.NewRequest("POST", "/p"+test.query, strings.NewReader(test.text))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d, got %d (%s)", test.name, test.code, w.Code, w.Body.String())
+			continue
+		}
+		if w.Code != http.StatusCreated {
+			continue
+		}
+		link, err := url.Parse(strings.TrimSpace(w.Body.String()))
+		if err != nil || link.Path != w.Header().Get("Location") || !validPasteID(strings.TrimPrefix(link.Path, "/p/")) {
+			t.Errorf("%s: unexpected url %q", test.name, w.Body.String())
+			continue
+		}
+
+		time.Sleep(time.Millisecond)
+		for i, code := range test.reads {
+			r := httptest.NewRequest("GET", link.Path+"?raw", nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != code {
+				t.Errorf("%s: read #%d, expected %d, got %d", test.name, i, code, w.Code)
+			}
+			if code == http.StatusOK && w.Body.String() != test.text {
+				t.Errorf("%s: read #%d, expected %q, got %q", test.name, i, test.text, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestPasteList(t *testing.T) {
+
+	h := PasteHandler(t.TempDir(), "/p", PasteOptions{List: RecentOperator})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/p", strings.NewReader("listed")))
+	id := strings.TrimPrefix(w.Header().Get("Location"), "/p/")
+
+	tests := []struct {
+		name, method, remote string
+		code                 int
+		listed               bool
+	}{
+		{"remote list", "GET", "192.0.2.1:1234", http.StatusOK, false},
+		{"remote delete", "DELETE", "192.0.2.1:1234", http.StatusForbidden, false},
+		{"operator list", "GET", "127.0.0.1:1234", http.StatusOK, true},
+		{"operator delete", "DELETE", "127.0.0.1:1234", http.StatusNoContent, false},
+		{"deleted", "GET", "127.0.0.1:1234", http.StatusOK, false},
+	}
+
+	for _, test := range tests {
+		target := "/p/"
+		if test.method == "DELETE" {
+			target += id
+		}
+		r := httptest.NewRequest(test.method, target, nil)
+		r.RemoteAddr = test.remote
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d, got %d", test.name, test.code, w.Code)
+		}
+		if listed := strings.Contains(w.Body.String(), ">"+id+"<"); listed != test.listed {
+			t.Errorf("%s: expected listed %v, got %v", test.name, test.listed, listed)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+
+	tests := []struct {
+		src, lang, expected string
+	}{
+		{`<b>`, "", `&lt;b&gt;`},
+		{`func f() // <x>`, "go", `<span class="k">func</span> f() <span class="c">// &lt;x&gt;</span>`},
+		{`s := "a\"<"`, "go", `s := <span class="s">&#34;a\&#34;&lt;&#34;</span>`},
+		{`x = 42 # no`, "python", `x = <span class="n">42</span> <span class="c"># no</span>`},
+		{`"unterminated`, "js", `<span class="s">&#34;unterminated</span>`},
+	}
+
+	for _, test := range tests {
+		if got := string(highlight(test.src, test.lang)); got != test.expected {
+			t.Errorf("%q (%s): expected %q, got %q", test.src, test.lang, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PipeHandler streams the body of a PUT or POST to "uri/<id>" straight to
+// the GETs of "uri/<id>", nothing is stored. the sender and the receivers
+// wait for each other up to 'timeout' (0: no limit); "?n=3", given by all
+// of them, makes the sender wait for 3 receivers. the sender goes only as
+// fast as the slowest receiver. "Content-Type", "Content-Length" and
+// "Content-Disposition" of the sender are passed on to the receivers.
+func PipeHandler(uri string, timeout time.Duration) http.Handler {
+	return &pipeHandler{uri: strings.TrimSuffix(uri, "/"), timeout: timeout, pipes: map[string]*pipe{}}
+}
+
+type pipeHandler struct {
+	uri     string
+	timeout time.Duration
+
+	mu    sync.Mutex
+	pipes map[string]*pipe // waiting for their sender or receivers
+}
+
+// pipe connects one sender with 'n' receivers.
+type pipe struct {
+	n         int
+	sender    bool
+	receivers []*pipeReceiver
+	ready     chan struct{} // closed once the sender and all receivers arrived
+}
+
+type pipeReceiver struct {
+	start chan pipeStart
+	gone  chan struct{} // closed when the receiver leaves
+}
+
+// pipeStart hands the stream over to a receiver.
+type pipeStart struct {
+	header http.Header
+	body   *io.PipeReader
+}
+
+var pipeHeaders = []string{"Content-Type", "Content-Length", "Content-Disposition"}
+
+func (ph *pipeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, ph.uri), "/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "missing pipe id, use "+ph.uri+"/<id>", http.StatusNotFound)
+		return
+	}
+	n := 1
+	if v := r.URL.Query().Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 1 {
+			http.Error(w, "invalid n", http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		ph.send(w, r, id, n)
+	case http.MethodGet:
+		ph.receive(w, r, id, n)
+	default:
+		writeStatus(w, http.StatusMethodNotAllowed)
+	}
+}
+
+// join returns the pipe 'id', created if needed. the number of receivers
+// has to match.
+func (ph *pipeHandler) join(id string, n int) (*pipe, error) {
+	p := ph.pipes[id]
+	if p == nil {
+		p = &pipe{n: n, ready: make(chan struct{})}
+		ph.pipes[id] = p
+	}
+	if p.n != n {
+		return nil, fmt.Errorf("pipe %q expects n=%d", id, p.n)
+	}
+	return p, nil
+}
+
+// connect starts the transfer once everybody arrived, the id is free
+// for the next transfer then.
+func (ph *pipeHandler) connect(id string, p *pipe) {
+	if p.sender && len(p.receivers) == p.n {
+		delete(ph.pipes, id)
+		close(p.ready)
+	}
+}
+
+// leave drops 'p' if nobody waits on it anymore.
+func (ph *pipeHandler) leave(id string, p *pipe) {
+	if !p.sender && len(p.receivers) == 0 && ph.pipes[id] == p {
+		delete(ph.pipes, id)
+	}
+}
+
+func (ph *pipeHandler) wait(r *http.Request, ch <-chan struct{}) bool {
+	var expired <-chan time.Time
+	if ph.timeout > 0 {
+		timer := time.NewTimer(ph.timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-ch:
+		return true
+	case <-expired:
+	case <-r.Context().Done():
+	}
+	return false
+}
+
+func (ph *pipeHandler) send(w http.ResponseWriter, r *http.Request, id string, n int) {
+
+	ph.mu.Lock()
+	p, err := ph.join(id, n)
+	if err == nil && p.sender {
+		err = fmt.Errorf("pipe %q has a sender already", id)
+	}
+	if err != nil {
+		ph.mu.Unlock()
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	p.sender = true
+	ph.connect(id, p)
+	ph.mu.Unlock()
+
+	if !ph.wait(r, p.ready) {
+		ph.mu.Lock()
+		select {
+		case <-p.ready: // the receivers arrived just now
+		default:
+			p.sender = false
+			ph.leave(id, p)
+			ph.mu.Unlock()
+			http.Error(w, "no receiver showed up", http.StatusRequestTimeout)
+			return
+		}
+		ph.mu.Unlock()
+	}
+
+	header := http.Header{}
+	for _, key := range pipeHeaders {
+		if v := r.Header.Get(key); v != "" {
+			header.Set(key, v)
+		}
+	}
+	if header.Get("Content-Length") == "" && r.ContentLength >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(r.ContentLength, 10))
+	}
+	fan := &pipeFanOut{}
+	for _, receiver := range p.receivers {
+		pr, pw := io.Pipe()
+		select {
+		case receiver.start <- pipeStart{header: header, body: pr}:
+			fan.writers = append(fan.writers, pw)
+		case <-receiver.gone:
+		}
+	}
+	if len(fan.writers) == 0 {
+		http.Error(w, "all receivers are gone", http.StatusBadGateway)
+		return
+	}
+
+	nBytes, err := io.Copy(fan, r.Body)
+	for _, pw := range fan.writers {
+		pw.CloseWithError(err)
+	}
+	AddLogNote(r, "pipe %q: %d bytes to %d of %d receivers", id, nBytes, len(fan.writers), n)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	fmt.Fprintf(w, "sent %d bytes to %d receivers\n", nBytes, len(fan.writers))
+}
+
+func (ph *pipeHandler) receive(w http.ResponseWriter, r *http.Request, id string, n int) {
+
+	ph.mu.Lock()
+	p, err := ph.join(id, n)
+	if err == nil && len(p.receivers) == p.n {
+		err = fmt.Errorf("pipe %q has all its %d receivers", id, p.n)
+	}
+	if err != nil {
+		ph.mu.Unlock()
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	receiver := &pipeReceiver{start: make(chan pipeStart), gone: make(chan struct{})}
+	defer close(receiver.gone)
+	p.receivers = append(p.receivers, receiver)
+	ph.connect(id, p)
+	ph.mu.Unlock()
+
+	if !ph.wait(r, p.ready) {
+		ph.mu.Lock()
+		select {
+		case <-p.ready:
+		default:
+			p.receivers = removeReceiver(p.receivers, receiver)
+			ph.leave(id, p)
+			ph.mu.Unlock()
+			http.Error(w, "no sender showed up", http.StatusRequestTimeout)
+			return
+		}
+		ph.mu.Unlock()
+	}
+
+	var start pipeStart
+	select {
+	case start = <-receiver.start:
+	case <-r.Context().Done():
+		return
+	}
+	defer start.body.Close()
+
+	for key, values := range start.header {
+		w.Header()[key] = values
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := start.body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				start.body.CloseWithError(werr)
+				return
+			}
+			rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func removeReceiver(receivers []*pipeReceiver, receiver *pipeReceiver) []*pipeReceiver {
+	for i := range receivers {
+		if receivers[i] == receiver {
+			return append(receivers[:i], receivers[i+1:]...)
+		}
+	}
+	return receivers
+}
+
+// pipeFanOut writes to all its writers, a failing writer is dropped. it
+// fails only when no writer is left.
+type pipeFanOut struct {
+	writers []*io.PipeWriter
+}
+
+func (fan *pipeFanOut) Write(p []byte) (int, error) {
+	alive := fan.writers[:0]
+	for _, pw := range fan.writers {
+		if _, err := pw.Write(p); err == nil {
+			alive = append(alive, pw)
+		}
+	}
+	fan.writers = alive
+	if len(alive) == 0 {
+		return 0, fmt.Errorf("all receivers are gone")
+	}
+	return len(p), nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPipe(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		receivers     int
+		receiverFirst bool
+	}{
+		{"sender-first", 1, false},
+		{"receiver-first", 1, true},
+		{"three-receivers", 3, false},
+	}
+
+	ts := httptest.NewServer(PipeHandler("/pipe", 5*time.Second))
+	defer ts.Close()
+	body := strings.Repeat("knut pipes ", 10000)
+
+	for _, test := range tests {
+		url := ts.URL + "/pipe/" + test.name
+		if test.receivers > 1 {
+			url += "?n=" + strconv.Itoa(test.receivers)
+		}
+
+		wg := sync.WaitGroup{}
+		received := make([]string, test.receivers)
+		ctypes := make([]string, test.receivers)
+		for i := 0; i < test.receivers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				resp, err := http.Get(url)
+				if err != nil {
+					t.Errorf("%s: %v", test.name, err)
+					return
+				}
+				defer resp.Body.Close()
+				data, _ := io.ReadAll(resp.Body)
+				received[i], ctypes[i] = string(data), resp.Header.Get("Content-Type")
+			}(i)
+		}
+		if test.receiverFirst {
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		req, _ := http.NewRequest("PUT", url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/x-knut")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: sender expected 200, got %d", test.name, resp.StatusCode)
+		}
+		wg.Wait()
+
+		for i := range received {
+			if received[i] != body {
+				t.Errorf("%s: receiver %d got %d bytes, expected %d", test.name, i, len(received[i]), len(body))
+			}
+			if ctypes[i] != "text/x-knut" {
+				t.Errorf("%s: receiver %d got type %q", test.name, i, ctypes[i])
+			}
+		}
+	}
+}
+
+func TestPipeTimeoutAndConflict(t *testing.T) {
+
+	ts := httptest.NewServer(PipeHandler("/pipe", 200*time.Millisecond))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/pipe/alone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Errorf("lonely receiver: expected 408, got %d", resp.StatusCode)
+	}
+
+	done := make(chan int)
+	go func() {
+		resp, err := http.Post(ts.URL+"/pipe/busy", "text/plain", strings.NewReader("first"))
+		if err != nil {
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	resp, err = http.Post(ts.URL+"/pipe/busy", "text/plain", strings.NewReader("second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("second sender: expected 409, got %d", resp.StatusCode)
+	}
+	resp, err = http.Get(ts.URL + "/pipe/busy?n=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("mismatched n: expected 409, got %d", resp.StatusCode)
+	}
+
+	if code := <-done; code != http.StatusRequestTimeout {
+		t.Errorf("lonely sender: expected 408, got %d", code)
+	}
+}
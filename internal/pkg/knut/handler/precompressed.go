@@ -0,0 +1,126 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// precompressedSiblings lists the encodings of the siblings of a file
+// which are looked for, in order of preference.
+var precompressedSiblings = []struct{ enc, ext string }{
+	{"br", ".br"},
+	{"zstd", ".zst"},
+	{"gzip", ".gz"},
+}
+
+// PrecompressedHandler serves an existing "name.br", "name.zst" or "name.gz"
+// instead of "name", if the client accepts that encoding. the response
+// keeps the Content-Type of "name", Range requests work on the encoded
+// bytes. 'resolve' maps the request to the local "name"; requests which
+// do not resolve to a regular file are passed to 'next'.
+func PrecompressedHandler(next http.Handler, resolve func(*http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		name := resolve(r)
+		if name == "" || !isRegularFile(name) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		encodings := []string{}
+		for _, sibling := range precompressedSiblings {
+			if isRegularFile(name + sibling.ext) {
+				encodings = append(encodings, sibling.enc)
+			}
+		}
+		if len(encodings) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// the response differs by Accept-Encoding, regardless of what
+		// this very client accepts.
+		addVary(w.Header(), "Accept-Encoding")
+
+		enc, _ := negotiateContentCoding(r.Header.Get("Accept-Encoding"), encodings)
+		if enc == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ext := ""
+		for _, sibling := range precompressedSiblings {
+			if sibling.enc == enc {
+				ext = sibling.ext
+			}
+		}
+
+		f, err := os.Open(name + ext)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentTypeOfFile(name))
+		w.Header().Set("Content-Encoding", enc)
+		w.Header().Set("ETag", fileETag(fi))
+		if r.Header.Get("Range") == "" {
+			// http.ServeContent leaves it out for encoded content
+			w.Header().Set("Content-Length", strconv.FormatInt(fi.Size(), 10))
+		}
+		http.ServeContent(w, r, name, fi.ModTime(), f)
+	})
+}
+
+// DirResolver maps the (prefix stripped) request path to a file below
+// 'root'. a request for a directory resolves to its "index.html", like
+// http.FileServer does it.
+func DirResolver(root string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		p := r.URL.Path
+		if !strings.HasPrefix(p, "/") {
+			p = "/" + p
+		}
+		name := filepath.Join(root, filepath.FromSlash(path.Clean(p)))
+		if strings.HasSuffix(p, "/") {
+			name = filepath.Join(name, "index.html")
+		}
+		return name
+	}
+}
+
+// contentTypeOfFile detects the Content-Type of 'name' by its extension
+// or, if that fails, by its first 512 bytes.
+func contentTypeOfFile(name string) string {
+	if ctype := mime.TypeByExtension(filepath.Ext(name)); ctype != "" {
+		return ctype
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+	buf := make([]byte, 512)
+	n, _ := io.ReadFull(f, buf)
+	return http.DetectContentType(buf[:n])
+}
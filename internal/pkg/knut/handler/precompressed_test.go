@@ -0,0 +1,83 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrecompressedHandler(t *testing.T) {
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"app.js":       "plain js",
+		"app.js.br":    "brotli js",
+		"app.js.zst":   "zstd js",
+		"app.js.gz":    "gzip js",
+		"style.css":    "plain css",
+		"style.css.gz": "gzip css",
+		"plain.txt":    "only plain",
+	}
+	for name, content := range files {
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "next")
+	})
+	h := PrecompressedHandler(next, DirResolver(dir))
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		rangeHdr       string
+		code           int
+		enc            string
+		ctype          string
+		vary           bool
+		body           string
+	}{
+		{"preferred", "/app.js", "gzip, zstd, br", "", http.StatusOK, "br", "text/javascript; charset=utf-8", true, "brotli js"},
+		{"q-value", "/app.js", "br;q=0.5, zstd;q=0.8, gzip;q=0.1", "", http.StatusOK, "zstd", "text/javascript; charset=utf-8", true, "zstd js"},
+		{"excluded", "/app.js", "*, br;q=0, zstd;q=0", "", http.StatusOK, "gzip", "text/javascript; charset=utf-8", true, "gzip js"},
+		{"only-gz", "/style.css", "br, gzip", "", http.StatusOK, "gzip", "text/css; charset=utf-8", true, "gzip css"},
+		{"not-accepted", "/style.css", "br", "", http.StatusOK, "", "", true, "next"},
+		{"no-accept", "/app.js", "", "", http.StatusOK, "", "", true, "next"},
+		{"range", "/app.js", "gzip", "bytes=0-3", http.StatusPartialContent, "gzip", "text/javascript; charset=utf-8", true, "gzip"},
+		{"no-sibling", "/plain.txt", "gzip, br", "", http.StatusOK, "", "", false, "next"},
+		{"missing", "/nope.js", "gzip", "", http.StatusOK, "", "", false, "next"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.path, nil)
+		if test.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", test.acceptEncoding)
+		}
+		if test.rangeHdr != "" {
+			r.Header.Set("Range", test.rangeHdr)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("%s: expected %d %q, got %d %q", test.name, test.code, test.body, w.Code, w.Body.String())
+		}
+		if enc := w.Header().Get("Content-Encoding"); enc != test.enc {
+			t.Errorf("%s: expected encoding %q, got %q", test.name, test.enc, enc)
+		}
+		if test.ctype != "" && w.Header().Get("Content-Type") != test.ctype {
+			t.Errorf("%s: expected Content-Type %q, got %q", test.name, test.ctype, w.Header().Get("Content-Type"))
+		}
+		if vary := strings.Contains(w.Header().Get("Vary"), "Accept-Encoding"); vary != test.vary {
+			t.Errorf("%s: expected Vary: Accept-Encoding %v, got %q", test.name, test.vary, w.Header().Get("Vary"))
+		}
+	}
+}
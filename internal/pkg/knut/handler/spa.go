@@ -0,0 +1,149 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"html"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SPAHandler serves the single-page-application found in 'root' via 'uri'.
+// existing files are served as they are. directories and unknown paths
+// without a file extension (client side routes like "/app/settings") are
+// answered with the "index.html" of 'root'. unknown paths with an extension
+// ("/app/missing.js") stay a 404, a missing asset should not turn into
+// html.
+//
+// if 'baseHref' is given, a <base href="baseHref"> is injected into the
+// <head> of the delivered "index.html".
+func SPAHandler(root, uri, baseHref string) http.Handler {
+
+	const indexName = "index.html"
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		name := filepath.Join(root, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		fi, err := os.Stat(name)
+
+		switch {
+		case err == nil && fi.IsDir():
+			if dirIndex := filepath.Join(name, indexName); isRegularFile(dirIndex) {
+				serveSPAIndex(w, r, dirIndex, baseHref)
+				return
+			}
+		case err == nil:
+			serveFileContent(w, r, name)
+			return
+		case path.Ext(r.URL.Path) != "":
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeStatus(w, http.StatusMethodNotAllowed)
+			return
+		}
+
+		serveSPAIndex(w, r, filepath.Join(root, indexName), baseHref)
+	})
+
+	// directories are answered by the (altered) index.html, not
+	// by a precompressed sibling of it.
+	resolveFile := DirResolver(root)
+	resolve := func(r *http.Request) string {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			return ""
+		}
+		return resolveFile(r)
+	}
+
+	return http.StripPrefix(strings.TrimSuffix(uri, "/"),
+		PrecompressedHandler(handler, resolve))
+}
+
+// serveSPAIndex delivers 'name' as html, with the <base> injected if
+// 'baseHref' is given.
+func serveSPAIndex(w http.ResponseWriter, r *http.Request, name, baseHref string) {
+
+	content, err := os.ReadFile(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var modTime time.Time
+	if fi, err := os.Stat(name); err == nil {
+		modTime = fi.ModTime()
+		w.Header().Set("ETag", fileETag(fi))
+	}
+
+	if baseHref != "" {
+		content = injectBaseHref(content, baseHref)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	http.ServeContent(w, r, name, modTime, bytes.NewReader(content))
+}
+
+// injectBaseHref inserts <base href="href"> right after the opening <head>
+// tag of 'doc' (not <header>). if there is no <head>, the <base> is
+// prepended.
+func injectBaseHref(doc []byte, href string) []byte {
+
+	base := []byte(`<base href="` + html.EscapeString(href) + `">`)
+
+	lower := bytes.ToLower(doc)
+	i := 0
+	for {
+		j := bytes.Index(lower[i:], []byte("<head"))
+		if j < 0 {
+			return append(base, doc...)
+		}
+		i += j + len("<head")
+		if i < len(lower) && strings.IndexByte(">\t\n\f\r ", lower[i]) >= 0 {
+			break
+		}
+	}
+	end := bytes.IndexByte(lower[i:], '>')
+	if end < 0 {
+		return append(base, doc...)
+	}
+	i += end + 1
+
+	out := make([]byte, 0, len(doc)+len(base))
+	out = append(out, doc[:i]...)
+	out = append(out, base...)
+	return append(out, doc[i:]...)
+}
+
+// serveFileContent serves the regular file 'name' without the
+// redirect-to-canonical-path logic of http.ServeFile.
+func serveFileContent(w http.ResponseWriter, r *http.Request, name string) {
+
+	f, err := os.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		writeStatus(w, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", fileETag(fi))
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+}
+
+func isRegularFile(name string) bool {
+	fi, err := os.Stat(name)
+	return err == nil && fi.Mode().IsRegular()
+}
@@ -0,0 +1,88 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInjectBaseHref(t *testing.T) {
+
+	tests := []struct {
+		doc, href, result string
+	}{
+		{"<html><head><title>x</title></head></html>", "/app/",
+			`<html><head><base href="/app/"><title>x</title></head></html>`},
+		{`<HEAD lang="en">`, "/a/", `<HEAD lang="en"><base href="/a/">`},
+		{"<p>no head</p>", "/", `<base href="/"><p>no head</p>`},
+		{"<head>", `/"x/`, `<head><base href="/&#34;x/">`},
+		{"<header>x</header>", "/", `<base href="/"><header>x</header>`},
+		{"<headline><head\n>", "/", "<headline><head\n><base href=\"/\">"},
+		{"<head", "/", `<base href="/"><head`},
+	}
+
+	for i, test := range tests {
+		out := string(injectBaseHref([]byte(test.doc), test.href))
+		t.Logf("case %d: %q => %q", i, test.doc, out)
+		if out != test.result {
+			t.Errorf("case %d: expected %q, got %q", i, test.result, out)
+		}
+	}
+}
+
+func TestSPAHandler(t *testing.T) {
+
+	root := t.TempDir()
+	files := map[string]string{
+		"index.html":          "<head></head>root",
+		"app.js":              "js",
+		"docs/index.html":     "<head></head>docs",
+		"assets/logo.txt":     "logo",
+		"assets/sub/note.txt": "note",
+	}
+	for name, content := range files {
+		name = filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(name), 0o755)
+		os.WriteFile(name, []byte(content), 0o644)
+	}
+	h := SPAHandler(root, "/app/", "/app/")
+
+	tests := []struct {
+		method, uri string
+		code        int
+		body        string
+	}{
+		{"GET", "/app/", http.StatusOK, `<head><base href="/app/"></head>root`},
+		{"GET", "/app/app.js", http.StatusOK, "js"},
+		{"GET", "/app/settings/profile", http.StatusOK, `<head><base href="/app/"></head>root`},
+		{"HEAD", "/app/settings", http.StatusOK, ""},
+		{"POST", "/app/settings", http.StatusMethodNotAllowed, ""},
+		{"GET", "/app/missing.js", http.StatusNotFound, ""},
+		{"GET", "/app/assets/missing.png", http.StatusNotFound, ""},
+		{"GET", "/app/docs/", http.StatusOK, `<head><base href="/app/"></head>docs`},
+		{"GET", "/app/docs", http.StatusOK, `<head><base href="/app/"></head>docs`},
+		{"GET", "/app/assets/", http.StatusOK, `<head><base href="/app/"></head>root`},
+		{"GET", "/app/assets/sub", http.StatusOK, `<head><base href="/app/"></head>root`},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.uri, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s %s: expected %d, got %d", test.method, test.uri, test.code, w.Code)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s %s: expected %q, got %q", test.method, test.uri, test.body, w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), "note.txt") {
+			t.Errorf("%s %s: got a listing %q", test.method, test.uri, w.Body.String())
+		}
+	}
+}
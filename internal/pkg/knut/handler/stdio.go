@@ -0,0 +1,203 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+)
+
+// StreamDownloadHandler serves the stream 'src' (knut's stdin) as the
+// download 'name'. without 'every' the first GET consumes 'src', all
+// later ones get "410 Gone". with 'every', 'src' is read right away and
+// spooled to a temporary file, every GET gets all of it, following the
+// spool until 'src' ends. 'delivered' (might be nil) is called after the
+// first complete download.
+func StreamDownloadHandler(src io.Reader, name string, every bool, delivered func()) http.Handler {
+	sh := &streamDownload{src: src, name: name, delivered: delivered}
+	if every {
+		sh.spool = newStreamSpool(src)
+	}
+	return sh
+}
+
+type streamDownload struct {
+	src       io.Reader
+	name      string
+	spool     *streamSpool
+	delivered func()
+
+	mu    sync.Mutex
+	taken bool
+	once  sync.Once
+}
+
+func (sh *streamDownload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	src := sh.src
+	if sh.spool != nil {
+		src = sh.spool.reader(r)
+	} else if r.Method == http.MethodGet {
+		sh.mu.Lock()
+		taken := sh.taken
+		sh.taken = true
+		sh.mu.Unlock()
+		if taken {
+			http.Error(w, "the stream was delivered already", http.StatusGone)
+			return
+		}
+	}
+
+	ctype := mime.TypeByExtension(path.Ext(sh.name))
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(sh.name)}))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+
+	n, err := io.Copy(flushWriter{w, http.NewResponseController(w)}, src)
+	AddLogNote(r, "stream %d bytes", n)
+	if err == nil && sh.delivered != nil {
+		sh.once.Do(sh.delivered)
+	}
+}
+
+// flushWriter pushes each write to the client, a stream might trickle in.
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if err == nil {
+		err = fw.rc.Flush()
+	}
+	return n, err
+}
+
+// streamSpool copies a stream into a temporary file which can be read by
+// many while it grows.
+type streamSpool struct {
+	file *os.File
+
+	mu      sync.Mutex
+	size    int64
+	err     error         // io.EOF once the stream ended
+	changed chan struct{} // closed and renewed on each change
+}
+
+func newStreamSpool(src io.Reader) *streamSpool {
+	spool := &streamSpool{changed: make(chan struct{})}
+	file, err := os.CreateTemp("", "knut-stream-")
+	if err != nil {
+		spool.err = err
+		return spool
+	}
+	os.Remove(file.Name()) // stays readable via 'file' where possible
+	spool.file = file
+
+	go func() {
+		buf, written := make([]byte, 32<<10), int64(0)
+		for {
+			n, err := src.Read(buf)
+			if n > 0 {
+				if _, werr := file.WriteAt(buf[:n], written); werr != nil {
+					n, err = 0, werr
+				}
+			}
+			written += int64(n)
+			spool.mu.Lock()
+			spool.size, spool.err = written, err
+			close(spool.changed)
+			spool.changed = make(chan struct{})
+			spool.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return spool
+}
+
+// reader reads the spool from its start, it ends with the stream or when
+// 'r' is canceled.
+func (spool *streamSpool) reader(r *http.Request) io.Reader {
+	return &spoolReader{spool: spool, r: r}
+}
+
+type spoolReader struct {
+	spool  *streamSpool
+	r      *http.Request
+	offset int64
+}
+
+func (sr *spoolReader) Read(p []byte) (int, error) {
+	for {
+		sr.spool.mu.Lock()
+		size, err, changed := sr.spool.size, sr.spool.err, sr.spool.changed
+		sr.spool.mu.Unlock()
+
+		if sr.offset < size {
+			p = p[:min(int64(len(p)), size-sr.offset)]
+			n, err := sr.spool.file.ReadAt(p, sr.offset)
+			sr.offset += int64(n)
+			if err == io.EOF {
+				err = nil
+			}
+			return n, err
+		}
+		if err != nil {
+			return 0, err
+		}
+		select {
+		case <-changed:
+		case <-sr.r.Context().Done():
+			return 0, sr.r.Context().Err()
+		}
+	}
+}
+
+// StreamReceiveHandler writes the body of each PUT or POST to 'dst'
+// (knut's stdout), one after the other. 'received' (might be nil) is
+// called after the first complete body.
+func StreamReceiveHandler(dst io.Writer, received func()) http.Handler {
+	var mu sync.Mutex
+	var once sync.Once
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			writeStatus(w, http.StatusMethodNotAllowed)
+			return
+		}
+		mu.Lock()
+		n, err := io.Copy(dst, r.Body)
+		mu.Unlock()
+		AddLogNote(r, "stream %d bytes", n)
+		if err != nil {
+			http.Error(w, err.Error(), statusForUploadError(err))
+			return
+		}
+		if received != nil {
+			once.Do(received)
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "received %d bytes\n", n)
+	})
+}
@@ -0,0 +1,112 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamDownload(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		every bool
+		codes []int
+	}{
+		{"once", false, []int{http.StatusOK, http.StatusGone, http.StatusGone}},
+		{"every", true, []int{http.StatusOK, http.StatusOK, http.StatusOK}},
+	}
+
+	for _, test := range tests {
+		delivered := 0
+		h := StreamDownloadHandler(strings.NewReader("streamed"), "/out.txt", test.every, func() { delivered++ })
+
+		for i, code := range test.codes {
+			r := httptest.NewRequest("GET", "/out.txt", nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != code {
+				t.Errorf("%s #%d: expected %d, got %d", test.name, i, code, w.Code)
+			}
+			if code == http.StatusOK && w.Body.String() != "streamed" {
+				t.Errorf("%s #%d: expected %q, got %q", test.name, i, "streamed", w.Body.String())
+			}
+			if ctype := w.Header().Get("Content-Type"); code == http.StatusOK && !strings.HasPrefix(ctype, "text/plain") {
+				t.Errorf("%s #%d: unexpected type %q", test.name, i, ctype)
+			}
+		}
+		if delivered != 1 {
+			t.Errorf("%s: expected 1 delivery, got %d", test.name, delivered)
+		}
+	}
+}
+
+func TestStreamDownloadFollows(t *testing.T) {
+
+	pr, pw := io.Pipe()
+	ts := httptest.NewServer(StreamDownloadHandler(pr, "/log", true, nil))
+	defer ts.Close()
+
+	received := make(chan string, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			resp, err := http.Get(ts.URL + "/log")
+			if err != nil {
+				received <- err.Error()
+				return
+			}
+			defer resp.Body.Close()
+			data, _ := io.ReadAll(resp.Body)
+			received <- string(data)
+		}()
+	}
+
+	pw.Write([]byte("first "))
+	time.Sleep(50 * time.Millisecond)
+	pw.Write([]byte("second"))
+	pw.Close()
+
+	for i := 0; i < 2; i++ {
+		if got := <-received; got != "first second" {
+			t.Errorf("receiver %d: expected %q, got %q", i, "first second", got)
+		}
+	}
+}
+
+func TestStreamReceive(t *testing.T) {
+
+	out := bytes.NewBuffer(nil)
+	received := 0
+	h := StreamReceiveHandler(out, func() { received++ })
+
+	tests := []struct {
+		method, body string
+		code         int
+	}{
+		{"POST", "one ", http.StatusOK},
+		{"PUT", "two", http.StatusOK},
+		{"GET", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/in", strings.NewReader(test.body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d, got %d", test.method, test.code, w.Code)
+		}
+	}
+	if out.String() != "one two" {
+		t.Errorf("expected %q on stdout, got %q", "one two", out.String())
+	}
+	if received != 1 {
+		t.Errorf("expected 1 call of received, got %d", received)
+	}
+}
@@ -6,15 +6,19 @@ package handler
 import (
 	"io"
 	"net/http"
-	"strings"
 )
 
+// TeeBodyHandler dumps the request bodies to 'ow' while 'next' reads
+// them. whatever 'next' leaves unread is dumped after it returned, 'next'
+// sees the whole body.
 func TeeBodyHandler(next http.Handler, ow io.Writer) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		mr := io.MultiReader(strings.NewReader("\n"), r.Body, strings.NewReader("\n"))
-		tr := io.TeeReader(mr, ow)
+		io.WriteString(ow, "\n")
+		tr := io.TeeReader(r.Body, ow)
 		r.Body = &teeReadCloser{tr, r.Body}
 		next.ServeHTTP(w, r)
+		io.Copy(io.Discard, tr)
+		io.WriteString(ow, "\n")
 	})
 }
 
@@ -25,10 +29,3 @@ type teeReadCloser struct {
 
 func (trc *teeReadCloser) Read(p []byte) (int, error) { return trc.r.Read(p) }
 func (trc *teeReadCloser) Close() error               { return trc.rc.Close() }
-
-func FlushBodyHandler(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		io.Copy(io.Discard, r.Body)
-		next.ServeHTTP(w, r)
-	})
-}
@@ -0,0 +1,399 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,creation-with-upload,termination,expiration"
+	tusPrefix     = ".knut-tus-"
+)
+
+// tusUpload is the state of one upload, kept as "dir/.knut-tus-<id>.json"
+// next to the received data "dir/.knut-tus-<id>.part" until it is
+// complete.
+type tusUpload struct {
+	Length   int64             `json:"length"`
+	Metadata map[string]string `json:"metadata"`
+	Remote   string            `json:"remote"`
+	Expires  time.Time         `json:"expires"`
+}
+
+// TusHandler accepts resumable uploads via the tus protocol 1.0 below
+// 'uri' (https://tus.io/protocols/resumable-upload): a POST to 'uri'
+// creates an upload, HEAD reports its offset, PATCH appends to it and
+// DELETE drops it. incomplete uploads are kept in 'dir' and dropped
+// 'expire' after their creation (0: never). complete uploads are moved
+// into place, named after their "filename" metadata via opts.Naming, and
+// forgotten. opts.Limits apply.
+func TusHandler(dir, uri string, expire time.Duration, opts UploadOptions) http.Handler {
+	if dir == "" {
+		dir = "."
+	}
+	os.MkdirAll(dir, 0o777)
+	opts.Limits = opts.Limits.track(dir)
+	th := &tusHandler{dir: dir, uri: strings.TrimSuffix(uri, "/"), expire: expire, opts: opts}
+	th.sweep()
+	return th
+}
+
+type tusHandler struct {
+	dir, uri string
+	expire   time.Duration
+	opts     UploadOptions
+
+	mu       sync.Mutex
+	inFlight map[string]bool // ids of the uploads being PATCHed
+}
+
+func (th *tusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", tusExtensions)
+		if th.opts.Limits.MaxFileSize > 0 {
+			w.Header().Set("Tus-Max-Size", strconv.FormatInt(th.opts.Limits.MaxFileSize, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		writeStatus(w, http.StatusPreconditionFailed)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, th.uri), "/")
+	if id == "" {
+		if r.Method != http.MethodPost {
+			writeStatus(w, http.StatusMethodNotAllowed)
+			return
+		}
+		th.create(w, r)
+		return
+	}
+	if !validTusID(id) {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		th.head(w, r, id)
+	case http.MethodPatch:
+		th.patch(w, r, id)
+	case http.MethodDelete:
+		th.terminate(w, r, id)
+	default:
+		writeStatus(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (th *tusHandler) create(w http.ResponseWriter, r *http.Request) {
+
+	th.sweep()
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "missing or invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if limit := th.opts.Limits.MaxFileSize; limit > 0 && length > limit {
+		http.Error(w, errUploadTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if left := th.opts.Limits.remaining(); left >= 0 && length > left {
+		http.Error(w, errQuotaExceeded.Error(), http.StatusInsufficientStorage)
+		return
+	}
+	metadata, err := parseTusMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := th.opts.Limits.checkName(metadata["filename"]); err != nil {
+		http.Error(w, err.Error(), statusForUploadError(err))
+		return
+	}
+
+	upload := tusUpload{Length: length, Metadata: metadata, Remote: remoteIP(r.RemoteAddr)}
+	if th.expire > 0 {
+		upload.Expires = time.Now().Add(th.expire).UTC()
+	}
+	rnd := make([]byte, 16)
+	rand.Read(rnd)
+	id := hex.EncodeToString(rnd)
+
+	if err := os.WriteFile(th.partName(id), nil, 0o666); err != nil {
+		http.Error(w, err.Error(), statusForFSError(err))
+		return
+	}
+	if err := th.save(id, upload); err != nil {
+		os.Remove(th.partName(id))
+		http.Error(w, err.Error(), statusForFSError(err))
+		return
+	}
+	AddLogNote(r, "tus create %s %q (%d bytes)", id, metadata["filename"], length)
+
+	w.Header().Set("Location", th.uri+"/"+id)
+	th.setExpires(w, upload)
+
+	// creation-with-upload
+	if r.Header.Get("Content-Type") == "application/offset+octet-stream" {
+		r.Header.Set("Upload-Offset", "0")
+		th.append(w, r, id, http.StatusCreated)
+		return
+	}
+	w.Header().Set("Upload-Offset", "0")
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (th *tusHandler) head(w http.ResponseWriter, r *http.Request, id string) {
+	upload, offset, err := th.load(id)
+	if err != nil {
+		th.notFound(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if len(upload.Metadata) > 0 {
+		w.Header().Set("Upload-Metadata", formatTusMetadata(upload.Metadata))
+	}
+	th.setExpires(w, upload)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (th *tusHandler) patch(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		writeStatus(w, http.StatusUnsupportedMediaType)
+		return
+	}
+	th.append(w, r, id, http.StatusNoContent)
+}
+
+// append adds the body of 'r' to the upload 'id' and answers with 'code'.
+func (th *tusHandler) append(w http.ResponseWriter, r *http.Request, id string, code int) {
+
+	th.mu.Lock()
+	if th.inFlight == nil {
+		th.inFlight = map[string]bool{}
+	}
+	if th.inFlight[id] {
+		th.mu.Unlock()
+		http.Error(w, "upload in progress", http.StatusConflict)
+		return
+	}
+	th.inFlight[id] = true
+	th.mu.Unlock()
+	defer func() {
+		th.mu.Lock()
+		delete(th.inFlight, id)
+		th.mu.Unlock()
+	}()
+
+	upload, offset, err := th.load(id)
+	if err != nil {
+		th.notFound(w, err)
+		return
+	}
+	if clientOffset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64); err != nil || clientOffset != offset {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		http.Error(w, fmt.Sprintf("expected Upload-Offset %d", offset), http.StatusConflict)
+		return
+	}
+	if err := th.opts.Limits.checkRequest(w, r); err != nil {
+		http.Error(w, err.Error(), statusForUploadError(err))
+		return
+	}
+	src, err := th.opts.Limits.wrap(upload.Metadata["filename"], r.Body, offset == 0)
+	if err != nil {
+		http.Error(w, err.Error(), statusForUploadError(err))
+		return
+	}
+
+	f, err := os.OpenFile(th.partName(id), os.O_WRONLY|os.O_APPEND, 0o666)
+	if err != nil {
+		http.Error(w, err.Error(), statusForFSError(err))
+		return
+	}
+	// an interrupted body still counts: the client resumes after the
+	// bytes which made it to the disk
+	n, err := io.Copy(f, io.LimitReader(src, upload.Length-offset))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	offset += n
+	AddLogNote(r, "tus patch %s (%d bytes, %d/%d)", id, n, offset, upload.Length)
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	th.setExpires(w, upload)
+	if err != nil {
+		http.Error(w, err.Error(), statusForUploadError(err))
+		return
+	}
+
+	if offset == upload.Length {
+		name, err := th.complete(r.Context(), id, upload, r.RemoteAddr)
+		if err != nil {
+			http.Error(w, err.Error(), statusForUploadError(err))
+			return
+		}
+		AddLogNote(r, "tus complete %s as %q", id, name)
+	}
+	w.WriteHeader(code)
+}
+
+// complete moves the data of upload 'id' into place, see
+// UploadOptions.finish, and drops its state. the name of the stored file
+// is returned. a rejected upload is dropped as well.
+func (th *tusHandler) complete(ctx context.Context, id string, upload tusUpload, remoteAddr string) (string, error) {
+	original, part := upload.Metadata["filename"], th.partName(id)
+	result := uploadResult{Name: th.opts.Naming.Name(original, remoteAddr, time.Now()), Size: upload.Length, Complete: true, staged: part}
+	if th.opts.Hook != nil {
+		result.SHA256, _ = sha256File(part)
+	}
+	err := th.opts.finish(ctx, th.dir, original, remoteAddr, &result)
+	th.remove(id)
+	return result.Name, err
+}
+
+func (th *tusHandler) terminate(w http.ResponseWriter, r *http.Request, id string) {
+	if _, _, err := th.load(id); err != nil {
+		th.notFound(w, err)
+		return
+	}
+	th.remove(id)
+	AddLogNote(r, "tus terminate %s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// load reads the state of upload 'id' and the number of bytes received.
+// expired uploads are removed.
+func (th *tusHandler) load(id string) (tusUpload, int64, error) {
+	upload := tusUpload{}
+	data, err := os.ReadFile(th.infoName(id))
+	if err == nil {
+		err = json.Unmarshal(data, &upload)
+	}
+	if err != nil {
+		return upload, 0, err
+	}
+	if !upload.Expires.IsZero() && time.Now().After(upload.Expires) {
+		th.remove(id)
+		return upload, 0, errTusExpired
+	}
+	fi, err := os.Stat(th.partName(id))
+	if err != nil {
+		return upload, 0, err
+	}
+	return upload, fi.Size(), nil
+}
+
+func (th *tusHandler) save(id string, upload tusUpload) error {
+	data, _ := json.Marshal(upload)
+	_, err := writeFileAtomic(th.infoName(id), strings.NewReader(string(data)))
+	return err
+}
+
+func (th *tusHandler) remove(id string) {
+	os.Remove(th.partName(id))
+	os.Remove(th.infoName(id))
+}
+
+// sweep removes the expired uploads and the abandoned state of uploads
+// without data, eg, left behind by a crash.
+func (th *tusHandler) sweep() {
+	infos, _ := filepath.Glob(filepath.Join(th.dir, tusPrefix+"*.json"))
+	for _, info := range infos {
+		id := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(info), tusPrefix), ".json")
+		if !validTusID(id) {
+			continue
+		}
+		if _, err := os.Stat(th.partName(id)); errors.Is(err, fs.ErrNotExist) {
+			os.Remove(info)
+			continue
+		}
+		th.load(id)
+	}
+}
+
+func (th *tusHandler) setExpires(w http.ResponseWriter, upload tusUpload) {
+	if !upload.Expires.IsZero() {
+		w.Header().Set("Upload-Expires", upload.Expires.Format(http.TimeFormat))
+	}
+}
+
+func (th *tusHandler) notFound(w http.ResponseWriter, err error) {
+	if errors.Is(err, errTusExpired) {
+		writeStatus(w, http.StatusGone)
+		return
+	}
+	writeStatus(w, http.StatusNotFound)
+}
+
+func (th *tusHandler) partName(id string) string {
+	return filepath.Join(th.dir, tusPrefix+id+".part")
+}
+
+func (th *tusHandler) infoName(id string) string {
+	return filepath.Join(th.dir, tusPrefix+id+".json")
+}
+
+var errTusExpired = errors.New("upload expired")
+
+func validTusID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// parseTusMetadata parses "key base64(value),key2 base64(value2)"
+func parseTusMetadata(s string) (map[string]string, error) {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata for %q", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+func formatTusMetadata(metadata map[string]string) string {
+	pairs := []string{}
+	for _, key := range slices.Sorted(maps.Keys(metadata)) {
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(metadata[key])))
+	}
+	return strings.Join(pairs, ",")
+}
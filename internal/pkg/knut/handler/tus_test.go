@@ -0,0 +1,165 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTusHandler(t *testing.T) {
+
+	dir := t.TempDir()
+	naming, _ := ParseUploadNaming("", "")
+	h := TusHandler(dir, "/tus/", time.Hour, UploadOptions{Naming: naming})
+
+	location := ""
+	do := func(method, path, body string, hdr ...string) *httptest.ResponseRecorder {
+		if path == "@" {
+			path = location
+		}
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Tus-Resumable", tusVersion)
+		for i := 0; i+1 < len(hdr); i += 2 {
+			r.Header.Set(hdr[i], hdr[i+1])
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if loc := w.Header().Get("Location"); loc != "" {
+			location = loc
+		}
+		return w
+	}
+
+	const octets = "application/offset+octet-stream"
+	filename := "filename " + base64.StdEncoding.EncodeToString([]byte("vm.img"))
+
+	steps := []struct {
+		method, path, body string
+		hdr                []string
+		code               int
+		offset             string
+	}{
+		{"OPTIONS", "/tus/", "", nil, http.StatusNoContent, ""},
+		{"POST", "/tus/", "", []string{"Tus-Resumable", ""}, http.StatusPreconditionFailed, ""},
+		{"POST", "/tus/", "", []string{"Upload-Length", "8", "Upload-Metadata", filename}, http.StatusCreated, "0"},
+		{"PATCH", "@", "knut", []string{"Upload-Offset", "0", "Content-Type", octets}, http.StatusNoContent, "4"},
+		{"HEAD", "@", "", nil, http.StatusOK, "4"},
+		{"PATCH", "@", "knut", []string{"Upload-Offset", "0", "Content-Type", octets}, http.StatusConflict, "4"},
+		{"PATCH", "@", "knut", []string{"Upload-Offset", "4", "Content-Type", "text/plain"}, http.StatusUnsupportedMediaType, ""},
+		{"PATCH", "@", "knut", []string{"Upload-Offset", "4", "Content-Type", octets}, http.StatusNoContent, "8"},
+		{"HEAD", "@", "", nil, http.StatusNotFound, ""}, // complete, forgotten
+		{"POST", "/tus/", "kn", []string{"Upload-Length", "4", "Content-Type", octets}, http.StatusCreated, "2"},
+		{"DELETE", "@", "", nil, http.StatusNoContent, ""},
+		{"HEAD", "@", "", nil, http.StatusNotFound, ""},
+		{"HEAD", "/tus/../../etc/passwd", "", nil, http.StatusNotFound, ""},
+	}
+
+	for i, step := range steps {
+		w := do(step.method, step.path, step.body, step.hdr...)
+		if w.Code != step.code || w.Header().Get("Upload-Offset") != step.offset {
+			t.Errorf("step %d: %s expected %d/%q, got %d/%q (%s)", i, step.method, step.code, step.offset,
+				w.Code, w.Header().Get("Upload-Offset"), w.Body.String())
+		}
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dir, "vm.img")); string(data) != "knutknut" {
+		t.Errorf("expected vm.img to contain %q, got %q", "knutknut", data)
+	}
+	if left, _ := filepath.Glob(filepath.Join(dir, tusPrefix+"*")); len(left) != 0 {
+		t.Errorf("expected no upload state, got %v", left)
+	}
+}
+
+func TestTusHandlerSweep(t *testing.T) {
+
+	// without an expiry incomplete uploads stay, the state of uploads
+	// without data goes
+	dir := t.TempDir()
+	files := []struct {
+		name, content string
+		kept          bool
+	}{
+		{tusPrefix + strings.Repeat("a", 32) + ".json", `{"length":8}`, true},
+		{tusPrefix + strings.Repeat("a", 32) + ".part", "knut", true},
+		{tusPrefix + strings.Repeat("b", 32) + ".json", `{"length":8}`, false},
+		{tusPrefix + "other.json", `{"length":8}`, true},
+	}
+	for _, f := range files {
+		os.WriteFile(filepath.Join(dir, f.name), []byte(f.content), 0o644)
+	}
+
+	naming, _ := ParseUploadNaming("", "")
+	h := TusHandler(dir, "/tus/", 0, UploadOptions{Naming: naming})
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(dir, f.name)); (err == nil) != f.kept {
+			t.Errorf("%s: expected kept %v, got %v", f.name, f.kept, err == nil)
+		}
+	}
+
+	r := httptest.NewRequest("HEAD", "/tus/"+strings.Repeat("a", 32), nil)
+	r.Header.Set("Tus-Resumable", tusVersion)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "4" {
+		t.Errorf("expected the incomplete upload at offset 4, got %d/%q", w.Code, w.Header().Get("Upload-Offset"))
+	}
+}
+
+func TestTusHandlerFinish(t *testing.T) {
+
+	// completed uploads pass the approval and are extracted like all others
+	reject := NewUploadApproval(func(context.Context, PendingUpload) (bool, bool, error) { return false, false, nil }, 0)
+	accept := NewUploadApproval(func(context.Context, PendingUpload) (bool, bool, error) { return true, false, nil }, 0)
+	archive := makeTar([]archiveEntry{{"a.txt", "aaa", false}}, false)
+
+	tests := []struct {
+		name     string
+		body     []byte
+		opts     UploadOptions
+		code     int
+		expected []string
+	}{
+		{"rejected.txt", []byte("knut"), UploadOptions{Approval: reject}, http.StatusForbidden, nil},
+		{"accepted.txt", []byte("knut"), UploadOptions{Approval: accept}, http.StatusCreated, []string{"accepted.txt"}},
+		{"files.tar", archive, UploadOptions{Extract: &Extractor{}}, http.StatusCreated, []string{"files/a.txt"}},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		test.opts.Naming, _ = ParseUploadNaming("", "")
+		h := TusHandler(dir, "/tus/", time.Hour, test.opts)
+
+		r := httptest.NewRequest("POST", "/tus/", strings.NewReader(string(test.body)))
+		r.Header.Set("Tus-Resumable", tusVersion)
+		r.Header.Set("Content-Type", "application/offset+octet-stream")
+		r.Header.Set("Upload-Length", strconv.Itoa(len(test.body)))
+		r.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte(test.name)))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d, got %d (%s)", test.name, test.code, w.Code, w.Body.String())
+		}
+		for _, name := range test.expected {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				t.Errorf("%s: expected %q: %v", test.name, name, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, test.name)); err == nil && test.code != http.StatusCreated {
+			t.Errorf("%s: rejected upload was stored", test.name)
+		}
+		if parts, _ := filepath.Glob(filepath.Join(dir, tusPrefix+"*")); len(parts) != 0 && test.code != http.StatusCreated {
+			t.Errorf("%s: expected the rejected upload to be dropped, got %v", test.name, parts)
+		}
+	}
+}
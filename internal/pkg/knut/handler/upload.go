@@ -4,52 +4,180 @@
 package handler
 
 import (
-	"fmt"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"io"
 	"log"
+	"mime"
 	"mime/multipart"
-	"net"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// UploadOptions configure UploadHandler
+type UploadOptions struct {
+	Naming   UploadNaming
+	Limits   UploadLimits
+	Versions *VersionStore   // keeps overwritten files, might be nil
+	Recent   string          // who sees the recently received files, RecentOff if empty
+	Hook     *UploadHook     // runs for each received file, might be nil
+	Extract  *Extractor      // unpacks received archives, might be nil
+	Approval *UploadApproval // asks the operator about each file, might be nil
+}
+
+// beforeOverwrite is handed to UploadNaming.Store
+func (opts UploadOptions) beforeOverwrite() func(string) error {
+	if opts.Versions == nil {
+		return nil
+	}
+	return opts.Versions.BeforeWrite
+}
+
+// afterWrite records the uploader of 'name' if revisions are kept
+func (opts UploadOptions) afterWrite(name, remoteAddr string) {
+	if opts.Versions != nil {
+		opts.Versions.AfterWrite(name, remoteAddr)
+	}
+}
+
+// stages reports if received files are held back until they are
+// accepted, see store.
+func (opts UploadOptions) stages() bool {
+	return opts.Approval != nil || opts.Hook != nil && opts.Hook.waits()
+}
+
+// store writes 'src' below 'dir' as 'name' (as returned by
+// UploadNaming.Name), obeying the collision policy. a file which still
+// needs to be accepted is staged in ".knut-pending" instead, finish
+// moves it into place.
+func (opts UploadOptions) store(dir, name string, src io.Reader) (uploadResult, error) {
+	if !opts.stages() {
+		name, n, err := opts.Naming.Store(dir, name, src, opts.beforeOverwrite())
+		return uploadResult{Name: name, Size: n}, err
+	}
+	if _, err := containedPath(dir, name); err != nil {
+		return uploadResult{Name: name}, err
+	}
+	pending := filepath.Join(dir, pendingDir)
+	if err := os.MkdirAll(pending, 0o777); err != nil {
+		return uploadResult{Name: name}, err
+	}
+	staged := filepath.Join(pending, strconv.FormatInt(time.Now().UnixNano(), 36)+"_"+sanitizeNameElement(path.Base(name)))
+	n, err := storeExclusive(staged, src)
+	return uploadResult{Name: name, Size: n, staged: staged}, err
+}
+
+// finish completes the file 'result' describes, stored below 'dir': a
+// staged file is handed to the operator and the hook and moved into
+// place once both accepted it, the operator is asked while 'ctx' is
+// alive. then the uploader is recorded, archives
+// are extracted and a hook which does not wait is started. 'result'
+// turns into a failure if the file is rejected or can't be moved into
+// place, the error is returned. a failed extraction keeps the archive
+// and is only reported.
+func (opts UploadOptions) finish(ctx context.Context, dir, original, remoteAddr string, result *uploadResult) error {
+	if result.Error != "" {
+		return nil
+	}
+	file := hookFile{name: original, sha256: result.SHA256, remote: remoteIP(remoteAddr), size: result.Size}
+	if result.staged != "" {
+		file.path = result.staged
+		err := opts.accept(ctx, dir, file)
+		target := ""
+		if err == nil {
+			target, err = opts.Naming.Place(dir, result.Name, result.staged, opts.beforeOverwrite())
+		}
+		if err != nil {
+			os.Remove(result.staged) // if not quarantined
+			opts.Limits.quota.give(result.Size)
+			result.Error, result.status, result.Complete = err.Error(), statusForUploadError(err), false
+			return err
+		}
+		rel, _ := filepath.Rel(dir, target)
+		result.Name, result.staged = filepath.ToSlash(rel), ""
+	}
+	file.path, _ = filepath.Abs(filepath.Join(dir, filepath.FromSlash(result.Name)))
+	opts.afterWrite(file.path, remoteAddr)
+	discard := func() {}
+	if opts.Extract != nil {
+		absDir, _ := filepath.Abs(dir)
+		folder, err := opts.Extract.extract(absDir, file.path, opts.Limits.quota)
+		if err != nil {
+			log.Printf("warning: extract %q: %v", result.Name, err)
+			result.ExtractError = err.Error()
+		} else if folder != "" {
+			discard = func() { opts.Extract.discard(file.path, opts.Limits.quota) }
+		}
+		result.Extracted = folder
+	}
+	if opts.Hook != nil && !opts.Hook.waits() {
+		opts.Hook.start(dir, file, discard) // the archive goes once the hook is done with it
+	} else {
+		discard()
+	}
+	return nil
+}
+
+// accept asks for the approval of the staged 'file' and runs the hook
+// for it, if the hook waits.
+func (opts UploadOptions) accept(ctx context.Context, dir string, file hookFile) error {
+	if opts.Approval != nil {
+		pending := PendingUpload{Remote: file.remote, Name: file.name, Size: file.size}
+		if err := opts.Approval.approve(ctx, file.path, pending); err != nil {
+			return err
+		}
+	}
+	if opts.Hook != nil && opts.Hook.waits() {
+		return opts.Hook.process(dir, file)
+	}
+	return nil
+}
+
 // UploadHandler handles uploads to a given 'dir'. for method "GET" an upload-form is
-// rendered, "POST" handles the actual upload
-func UploadHandler(dir string) http.Handler {
-
-	const htmlDoc = `<!doctype html>
-<head>
-	<title>knut - file upload</title>
-	<style type="text/css">
-* { font-family: monospace }
-input[type="submit"] { margin-top: 1em }
-	</style>
-</head>
-<h1>knut - file upload</h1>`
-
-	const uploadForm = `<form method="post" enctype="multipart/form-data">
-	<div>
-		<div><input type="file" name="upload_file"></div>
-	</div>
-	<div>
-		<input type="submit" value="Upload">
-	</div>
-</form>
-`
+// rendered (drag and drop, progress, recently received files), "POST"
+// handles the actual upload. the relative paths of
+// uploaded folders are kept below 'dir'. the result of each file is
+// reported, as JSON if asked for. raw bodies sent via "PUT" or
+// "POST" to "uri/<name>" are stored as "<name>", see rawUploader. the
+// names of the stored files are picked via opts.Naming.
+func UploadHandler(dir, uri string, opts UploadOptions) http.Handler {
 
 	os.MkdirAll(dir, 0777)
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	opts.Limits = opts.Limits.track(dir)
+	history := &uploadHistory{}
+	raw := &rawUploader{dir: dir, uri: uri, opts: opts, history: history}
+
+	renderPage := func(w http.ResponseWriter, r *http.Request, code int, page uploadPage) {
+		if page.ShowRecent = showRecent(opts.Recent, r); page.ShowRecent {
+			page.Recent = history.list()
+		}
+		body := bytes.NewBuffer(nil)
+		uploadPageTmpl.Execute(body, page)
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+		w.WriteHeader(code)
+		if r.Method != http.MethodHead {
+			w.Write(body.Bytes())
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isRawUpload(r) {
+			raw.ServeHTTP(w, r)
+			return
+		}
 		switch r.Method {
 		case "POST":
-		case "GET":
-			defer fmt.Fprint(w, htmlDoc, uploadForm)
-			fallthrough
-		case "HEAD":
-			w.Header().Set("Content-Length", strconv.Itoa(len(htmlDoc)+len(uploadForm)))
+		case "GET", "HEAD":
+			renderPage(w, r, http.StatusOK, uploadPage{})
 			return
 		default:
 			writeStatus(w, http.StatusMethodNotAllowed)
@@ -57,49 +185,97 @@ input[type="submit"] { margin-top: 1em }
 		}
 
 		startTime := time.Now()
-		r.ParseMultipartForm(4096)
-
-		if r.MultipartForm == nil {
+		if err := opts.Limits.checkRequest(w, r); err != nil {
+			writeStatus(w, statusForUploadError(err))
+			return
+		}
+		mr, err := r.MultipartReader()
+		if err != nil {
 			writeStatus(w, http.StatusBadRequest)
 			return
 		}
 
-		var nBytes int64
-		for _, files := range r.MultipartForm.File {
-			for _, fh := range files {
-				prefix := genNamePrefix(r.RemoteAddr, fh.Filename)
-				n, err := storeFormFile(prefix, dir, fh)
-				if err != nil {
-					log.Printf("warning: %v", err)
-				}
-				nBytes += n
+		r = withApprovalBatch(r) // "accept all" covers the files of this request
+		results, nBytes, code := []uploadResult{}, int64(0), http.StatusOK
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				results = append(results, uploadResult{Error: err.Error(), status: statusForUploadError(err)})
+				break
+			}
+			if formFileName(part) == "" {
+				part.Close()
+				continue
+			}
+			if opts.Limits.MaxFiles > 0 && len(results) >= opts.Limits.MaxFiles {
+				part.Close()
+				results = append(results, uploadResult{Name: formFileName(part), Error: errTooManyFiles.Error(), status: statusForUploadError(errTooManyFiles)})
+				break
+			}
+			result := storeFormPart(r.Context(), dir, part, r.RemoteAddr, opts)
+			part.Close()
+			if result.Error != "" {
+				log.Printf("warning: upload %q: %s", result.Name, result.Error)
+			} else {
+				AddLogNote(r, "upload %q (%d bytes)", result.Name, result.Size)
+				history.add(result, r.RemoteAddr)
+				nBytes += result.Size
+			}
+			results = append(results, result)
+		}
+		for _, result := range results {
+			if result.status != 0 {
+				code = result.status
+				break
 			}
 		}
 
-		fmt.Fprintln(w, htmlDoc)
-		fmt.Fprintf(w, "ok, received %d bytes over %s", nBytes, time.Since(startTime))
+		if strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(results)
+			return
+		}
+
+		renderPage(w, r, code, uploadPage{
+			Posted: true, Results: results, Received: nBytes, Duration: time.Since(startTime),
+		})
 	})
 }
 
-func genNamePrefix(hostport, name string) string {
-	host, port, _ := net.SplitHostPort(hostport)
-	ip := net.ParseIP(host)
-	base := filepath.Base(name)
-	return fmt.Sprintf("%x_%s_%s_", ip, port, base)
+// formFileName returns the filename of 'part' as sent by the client,
+// including the relative path of files in uploaded folders (which
+// multipart.Part.FileName strips).
+func formFileName(part *multipart.Part) string {
+	_, params, err := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+	if err != nil {
+		return ""
+	}
+	return params["filename"]
 }
 
-func storeFormFile(prefix, dir string, fh *multipart.FileHeader) (int64, error) {
+// storeFormPart stores the file carried in 'part'.
+func storeFormPart(ctx context.Context, dir string, part *multipart.Part, remoteAddr string, opts UploadOptions) uploadResult {
 
-	postedFile, err := fh.Open()
-	if err != nil {
-		return 0, err
+	filename := formFileName(part)
+	failed := func(n int64, err error) uploadResult {
+		return uploadResult{Name: filename, Size: n, Error: err.Error(), status: statusForUploadError(err)}
 	}
-	defer postedFile.Close()
 
-	osFile, err := os.CreateTemp(dir, prefix)
+	src, err := opts.Limits.wrap(filename, part, true)
+	if err != nil {
+		return failed(0, err)
+	}
+	h := sha256.New()
+	name := opts.Naming.PathName(filename, remoteAddr, time.Now())
+	result, err := opts.store(dir, name, io.TeeReader(src, h))
 	if err != nil {
-		return 0, err
+		return failed(result.Size, err)
 	}
-	defer osFile.Close()
-	return io.Copy(osFile, postedFile)
+	result.SHA256, result.Complete = hex.EncodeToString(h.Sum(nil)), true
+	opts.finish(ctx, dir, filename, remoteAddr, &result)
+	return result
 }
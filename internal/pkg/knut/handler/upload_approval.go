@@ -0,0 +1,114 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const pendingDir = ".knut-pending"
+
+var errUploadRejected = errors.New("rejected by the operator")
+
+// PendingUpload describes a received file waiting for its approval.
+type PendingUpload struct {
+	Remote string // ip address of the client
+	Name   string // as sent by the client
+	Size   int64
+	Type   string // sniffed from the content
+}
+
+// UploadApproval holds each received file in ".knut-pending" until 'ask'
+// decides about it, only accepted files meet the collision policy. 'ask'
+// is called for one file at a time. once it answers with 'all', the
+// remaining files of the same request are accepted without asking, the
+// next request is asked about again. a failing 'ask', a gone client and
+// no answer within 'timeout' (0: no limit, counted from the arrival of
+// the file) reject the file.
+type UploadApproval struct {
+	ask     func(context.Context, PendingUpload) (accept, all bool, err error)
+	timeout time.Duration
+	turn    chan struct{} // one question at a time
+}
+
+func NewUploadApproval(ask func(context.Context, PendingUpload) (accept, all bool, err error), timeout time.Duration) *UploadApproval {
+	return &UploadApproval{ask: ask, timeout: timeout, turn: make(chan struct{}, 1)}
+}
+
+// approvalBatchKey carries the *approvalBatch of a request
+type approvalBatchKey struct{}
+
+// approvalBatch remembers an 'all' answer for the files of one request,
+// see withApprovalBatch.
+type approvalBatch struct {
+	all bool
+}
+
+// withApprovalBatch lets an 'all' answer cover the files which follow in
+// 'r'. without a batch 'all' accepts only the file asked about.
+func withApprovalBatch(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), approvalBatchKey{}, &approvalBatch{}))
+}
+
+func (a *UploadApproval) decide(ctx context.Context, file PendingUpload) error {
+	batch, _ := ctx.Value(approvalBatchKey{}).(*approvalBatch)
+	if batch != nil && batch.all {
+		return nil
+	}
+	if a.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.timeout)
+		defer cancel()
+	}
+	var accept, all bool
+	var err error
+	select {
+	case a.turn <- struct{}{}:
+		if err = ctx.Err(); err == nil {
+			accept, all, err = a.ask(ctx, file)
+		}
+		<-a.turn
+		if err == nil {
+			err = ctx.Err() // an answer after the timeout is too late
+		}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: approval of %q: %v\n", file.Name, err)
+		return fmt.Errorf("%w: %v", errUploadRejected, err)
+	}
+	if batch != nil {
+		batch.all = all
+	}
+	if !accept && !all {
+		return errUploadRejected
+	}
+	return nil
+}
+
+// approve asks about the received file staged at 'path', see
+// UploadOptions.store, while 'ctx' is alive. errUploadRejected is
+// returned if it is rejected.
+func (a *UploadApproval) approve(ctx context.Context, path string, file PendingUpload) error {
+	file.Type = sniffFileType(path)
+	return a.decide(ctx, file)
+}
+
+func sniffFileType(name string) string {
+	f, err := os.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	return http.DetectContentType(head[:n])
+}
@@ -0,0 +1,247 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadApproval(t *testing.T) {
+
+	type answer struct {
+		accept, all bool
+		err         error
+	}
+
+	tests := []struct {
+		name    string
+		answers []answer
+		codes   []int
+		asked   int
+	}{
+		{"accept", []answer{{true, false, nil}}, []int{http.StatusCreated}, 1},
+		{"reject", []answer{{false, false, nil}}, []int{http.StatusForbidden}, 1},
+		{"failed", []answer{{true, false, errors.New("no terminal")}}, []int{http.StatusForbidden}, 1},
+		// each request is a batch of its own, see TestUploadApprovalBatch
+		{"all", []answer{{false, true, nil}, {false, true, nil}}, []int{http.StatusCreated, http.StatusCreated}, 2},
+		{"each", []answer{{true, false, nil}, {false, false, nil}}, []int{http.StatusCreated, http.StatusForbidden}, 2},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		asked := 0
+		approval := NewUploadApproval(func(_ context.Context, p PendingUpload) (bool, bool, error) {
+			if p.Name != test.name || p.Size != 4 || !strings.HasPrefix(p.Type, "text/plain") {
+				t.Errorf("%s: unexpected pending upload %+v", test.name, p)
+			}
+			if _, err := os.Stat(filepath.Join(dir, test.name)); err == nil && asked == 0 {
+				t.Errorf("%s: file visible before approval", test.name)
+			}
+			a := test.answers[asked]
+			asked++
+			return a.accept, a.all, a.err
+		}, 0)
+		naming, _ := ParseUploadNaming("", "")
+		h := UploadHandler(dir, "/upload", UploadOptions{Naming: naming, Approval: approval})
+
+		for i, code := range test.codes {
+			r := httptest.NewRequest("PUT", "/upload/"+test.name, strings.NewReader("knut"))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != code {
+				t.Errorf("%s #%d: expected %d, got %d (%s)", test.name, i, code, w.Code, w.Body.String())
+			}
+		}
+		if asked != test.asked {
+			t.Errorf("%s: expected %d questions, got %d", test.name, test.asked, asked)
+		}
+		if held, _ := os.ReadDir(filepath.Join(dir, pendingDir)); len(held) != 0 {
+			t.Errorf("%s: expected no pending files, got %d", test.name, len(held))
+		}
+	}
+}
+
+func TestUploadApprovalCollision(t *testing.T) {
+
+	// "precious" exists, the upload meets the collision policy only once
+	// it is accepted
+	tests := []struct {
+		collision string
+		accept    bool
+		code      int
+		stored    string // name of the upload, "" if it was dropped
+	}{
+		{CollisionOverwrite, false, http.StatusForbidden, ""},
+		{CollisionOverwrite, true, http.StatusCreated, "precious"},
+		{CollisionReject, false, http.StatusForbidden, ""},
+		{CollisionReject, true, http.StatusConflict, ""},
+		{CollisionSuffix, false, http.StatusForbidden, ""},
+		{CollisionSuffix, true, http.StatusCreated, "precious-1"},
+	}
+
+	for _, test := range tests {
+		name := fmt.Sprintf("%s/%v", test.collision, test.accept)
+		dir := t.TempDir()
+		precious := filepath.Join(dir, "precious")
+		os.WriteFile(precious, []byte("precious"), 0o644)
+
+		approval := NewUploadApproval(func(_ context.Context, p PendingUpload) (bool, bool, error) {
+			if data, _ := os.ReadFile(precious); string(data) != "precious" {
+				t.Errorf("%s: touched before the approval: %q", name, data)
+			}
+			if held, _ := os.ReadDir(filepath.Join(dir, pendingDir)); len(held) != 1 {
+				t.Errorf("%s: expected the upload in %s, got %d files", name, pendingDir, len(held))
+			}
+			return test.accept, false, nil
+		}, 0)
+		naming, _ := ParseUploadNaming("", test.collision)
+		h := UploadHandler(dir, "/upload", UploadOptions{Naming: naming, Approval: approval})
+
+		r := httptest.NewRequest("PUT", "/upload/precious", strings.NewReader("knut"))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d, got %d (%s)", name, test.code, w.Code, w.Body.String())
+		}
+
+		expected := map[string]string{"precious": "precious"}
+		if test.stored != "" {
+			expected[test.stored] = "knut"
+		}
+		for file, content := range expected {
+			if data, _ := os.ReadFile(filepath.Join(dir, file)); string(data) != content {
+				t.Errorf("%s: expected %s to contain %q, got %q", name, file, content, data)
+			}
+		}
+		if held, _ := os.ReadDir(filepath.Join(dir, pendingDir)); len(held) != 0 {
+			t.Errorf("%s: expected no pending files, got %d", name, len(held))
+		}
+	}
+}
+
+func TestUploadApprovalBatch(t *testing.T) {
+
+	// "all" covers the remaining files of the request, not the next one
+	dir := t.TempDir()
+	asked := []string{}
+	approval := NewUploadApproval(func(_ context.Context, p PendingUpload) (bool, bool, error) {
+		asked = append(asked, p.Name)
+		return false, p.Name == "a.txt" || p.Name == "d.txt", nil
+	}, 0)
+	naming, _ := ParseUploadNaming("", "")
+	h := UploadHandler(dir, "/upload", UploadOptions{Naming: naming, Approval: approval})
+
+	requests := []struct {
+		files   []string
+		asked   []string
+		code    int
+		created []string
+	}{
+		{[]string{"a.txt", "b.txt", "c.txt"}, []string{"a.txt"}, http.StatusOK, []string{"a.txt", "b.txt", "c.txt"}},
+		{[]string{"e.txt", "d.txt", "f.txt"}, []string{"e.txt", "d.txt"}, http.StatusForbidden, []string{"d.txt", "f.txt"}},
+	}
+
+	for i, req := range requests {
+		asked = asked[:0]
+		body := bytes.NewBuffer(nil)
+		mw := multipart.NewWriter(body)
+		for _, name := range req.files {
+			hdr := textproto.MIMEHeader{}
+			hdr.Set("Content-Disposition", fmt.Sprintf(`form-data; name="upload"; filename=%q`, name))
+			pw, _ := mw.CreatePart(hdr)
+			pw.Write([]byte("knut"))
+		}
+		mw.Close()
+
+		r := httptest.NewRequest("POST", "/upload", body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		r.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != req.code {
+			t.Errorf("#%d: expected %d, got %d (%s)", i, req.code, w.Code, w.Body.String())
+		}
+		if strings.Join(asked, ",") != strings.Join(req.asked, ",") {
+			t.Errorf("#%d: expected questions about %v, got %v", i, req.asked, asked)
+		}
+		results := []uploadResult{}
+		json.Unmarshal(w.Body.Bytes(), &results)
+		created := []string{}
+		for _, result := range results {
+			if result.Error == "" {
+				created = append(created, result.Name)
+			}
+		}
+		if strings.Join(created, ",") != strings.Join(req.created, ",") {
+			t.Errorf("#%d: expected %v, got %v", i, req.created, created)
+		}
+	}
+}
+
+func TestUploadApprovalGone(t *testing.T) {
+
+	// an unanswered question rejects the file once the timeout fires or
+	// the client is gone
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		cancel  bool // the request context ends while asking
+	}{
+		{"timeout", 50 * time.Millisecond, false},
+		{"cancel", 0, true},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		ctx, cancel := context.WithCancel(context.Background())
+		approval := NewUploadApproval(func(ctx context.Context, p PendingUpload) (bool, bool, error) {
+			if test.cancel {
+				cancel()
+			}
+			<-ctx.Done()
+			return false, false, ctx.Err()
+		}, test.timeout)
+		naming, _ := ParseUploadNaming("", "")
+		h := UploadHandler(dir, "/upload", UploadOptions{Naming: naming, Approval: approval})
+
+		r := httptest.NewRequest("PUT", "/upload/"+test.name, strings.NewReader("knut")).WithContext(ctx)
+		w := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			h.ServeHTTP(w, r)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(3 * time.Second):
+			cancel()
+			t.Fatalf("%s: still waiting for an answer", test.name)
+		}
+		cancel()
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected %d, got %d (%s)", test.name, http.StatusForbidden, w.Code, w.Body.String())
+		}
+		if _, err := os.Stat(filepath.Join(dir, test.name)); err == nil {
+			t.Errorf("%s: expected the file dropped", test.name)
+		}
+		if held, _ := os.ReadDir(filepath.Join(dir, pendingDir)); len(held) != 0 {
+			t.Errorf("%s: expected no pending files, got %d", test.name, len(held))
+		}
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	errUnsafeEntry    = errors.New("archive entry escapes the target folder")
+	errTooManyEntries = errors.New("archive has too many entries")
+	errExpandedSize   = errors.New("archive expands beyond the size limit")
+)
+
+// Extractor unpacks uploaded zip, tar and tar.gz archives into a folder
+// next to the archive, named after it. entries must stay inside that
+// folder, links are skipped. at most 'MaxEntries' entries and 'MaxSize'
+// expanded bytes are accepted (0: no limit), otherwise nothing is kept.
+type Extractor struct {
+	MaxEntries  int
+	MaxSize     int64
+	KeepArchive bool
+}
+
+// archiveKind detects the type of the archive 'name' by its content:
+// "zip", "tar", "tar.gz" or "" for anything else.
+func archiveKind(name string) string {
+	f, err := os.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+
+	isTar := func(head []byte) bool {
+		return len(head) >= 262 && bytes.Equal(head[257:262], []byte("ustar"))
+	}
+
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return "zip"
+	case isTar(head):
+		return "tar"
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		f.Seek(0, io.SeekStart)
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return ""
+		}
+		inner := make([]byte, 512)
+		n, _ := io.ReadFull(gz, inner)
+		if isTar(inner[:n]) {
+			return "tar.gz"
+		}
+	}
+	return ""
+}
+
+// extract unpacks 'archive' if it is one, the unpacked files count against
+// 'quota' (nil: unlimited). the name of the created folder, relative to
+// 'dir', is returned; "" if 'archive' is no archive. the archive stays,
+// see discard.
+func (ex *Extractor) extract(dir, archive string, quota *uploadQuota) (string, error) {
+
+	kind := archiveKind(archive)
+	if kind == "" {
+		return "", nil
+	}
+
+	base := filepath.Base(archive)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(strings.ToLower(base), ext) {
+			base = base[:len(base)-len(ext)]
+			break
+		}
+	}
+	if base == "" || base == filepath.Base(archive) {
+		base += "_extracted"
+	}
+	folder, err := UploadNaming{Collision: CollisionSuffix}.Target(filepath.Dir(archive), base)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Mkdir(folder, 0o777); err != nil {
+		return "", err
+	}
+
+	budget := int64(-1)
+	if ex.MaxSize > 0 {
+		budget = ex.MaxSize
+	}
+	ax := &archiveWriter{folder: folder, left: budget, maxEntries: ex.MaxEntries, quota: quota}
+
+	switch kind {
+	case "zip":
+		err = ax.unzip(archive)
+	case "tar", "tar.gz":
+		err = ax.untar(archive, kind == "tar.gz")
+	}
+	if err != nil {
+		os.RemoveAll(folder)
+		quota.give(ax.stored)
+		return "", err
+	}
+	rel, _ := filepath.Rel(dir, folder)
+	return filepath.ToSlash(rel), nil
+}
+
+// discard removes the extracted 'archive' unless it is kept.
+func (ex *Extractor) discard(archive string, quota *uploadQuota) {
+	if ex.KeepArchive {
+		return
+	}
+	if fi, err := os.Stat(archive); err == nil && os.Remove(archive) == nil {
+		quota.give(fi.Size())
+	}
+}
+
+// archiveWriter creates the entries of an archive below 'folder'.
+type archiveWriter struct {
+	folder     string
+	left       int64 // bytes, -1: unlimited
+	maxEntries int   // 0: unlimited
+	counted    int
+	quota      *uploadQuota // might be nil
+	stored     int64        // bytes taken from 'quota'
+}
+
+// target checks 'name' and counts the entry.
+func (ax *archiveWriter) target(name string) (string, error) {
+	ax.counted++
+	if ax.maxEntries > 0 && ax.counted > ax.maxEntries {
+		return "", errTooManyEntries
+	}
+	local := filepath.FromSlash(strings.TrimSuffix(name, "/"))
+	if local == "" || !filepath.IsLocal(local) || strings.ContainsRune(name, '\\') {
+		return "", fmt.Errorf("%w: %q", errUnsafeEntry, name)
+	}
+	return filepath.Join(ax.folder, local), nil
+}
+
+func (ax *archiveWriter) mkdir(name string) error {
+	target, err := ax.target(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(target, 0o777)
+}
+
+func (ax *archiveWriter) create(name string, src io.Reader) error {
+	target, err := ax.target(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o777); err != nil {
+		return err
+	}
+	if ax.left >= 0 {
+		src = &limitedUploadReader{r: src, left: ax.left, err: errExpandedSize}
+	}
+	if ax.quota != nil {
+		src = &quotaReader{r: src, q: ax.quota}
+	}
+	n, err := storeExclusive(target, src)
+	if ax.left >= 0 {
+		ax.left -= n
+	}
+	if err == nil {
+		ax.stored += n
+	}
+	return err
+}
+
+func (ax *archiveWriter) unzip(archive string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	if ax.maxEntries > 0 && len(zr.File) > ax.maxEntries {
+		return errTooManyEntries
+	}
+	for _, f := range zr.File {
+		switch mode := f.Mode(); {
+		case mode.IsDir():
+			err = ax.mkdir(f.Name)
+		case mode.IsRegular():
+			var rc io.ReadCloser
+			if rc, err = f.Open(); err == nil {
+				err = ax.create(f.Name, rc)
+				rc.Close()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ax *archiveWriter) untar(archive string, gzipped bool) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var src io.Reader = bufio.NewReader(f)
+	if gzipped {
+		gz, err := gzip.NewReader(src)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = ax.mkdir(hdr.Name)
+		case tar.TypeReg:
+			err = ax.create(hdr.Name, tr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
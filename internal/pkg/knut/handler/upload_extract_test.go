@@ -0,0 +1,160 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+type archiveEntry struct {
+	name, body string
+	link       bool
+}
+
+func makeZip(entries []archiveEntry) []byte {
+	buf := bytes.NewBuffer(nil)
+	zw := zip.NewWriter(buf)
+	for _, e := range entries {
+		f, _ := zw.Create(e.name)
+		f.Write([]byte(e.body))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func makeTar(entries []archiveEntry, gzipped bool) []byte {
+	buf := bytes.NewBuffer(nil)
+	var gz *gzip.Writer
+	tw := tar.NewWriter(buf)
+	if gzipped {
+		gz = gzip.NewWriter(buf)
+		tw = tar.NewWriter(gz)
+	}
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.body)), Typeflag: tar.TypeReg, Format: tar.FormatUSTAR}
+		if e.link {
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.body, 0
+		}
+		tw.WriteHeader(hdr)
+		if !e.link {
+			tw.Write([]byte(e.body))
+		}
+	}
+	tw.Close()
+	if gz != nil {
+		gz.Close()
+	}
+	return buf.Bytes()
+}
+
+func TestUploadExtract(t *testing.T) {
+
+	files := []archiveEntry{{"a.txt", "aaa", false}, {"sub/b.txt", "bbbb", false}}
+
+	tests := []struct {
+		name     string
+		archive  []byte
+		extract  Extractor
+		folder   string
+		expected []string // files inside the folder
+		failed   bool
+		kept     bool
+	}{
+		{"files.zip", makeZip(files), Extractor{KeepArchive: true}, "files", []string{"a.txt", "sub/b.txt"}, false, true},
+		{"files.tar", makeTar(files, false), Extractor{}, "files", []string{"a.txt", "sub/b.txt"}, false, false},
+		{"files.tar.gz", makeTar(files, true), Extractor{}, "files", []string{"a.txt", "sub/b.txt"}, false, false},
+		{"files.tgz", makeTar(files, true), Extractor{MaxEntries: 2, MaxSize: 7}, "files", []string{"a.txt", "sub/b.txt"}, false, false},
+		{"plain.txt", []byte("no archive"), Extractor{}, "", nil, false, true},
+		{"slip.zip", makeZip([]archiveEntry{{"ok.txt", "ok", false}, {"../evil.txt", "evil", false}}), Extractor{}, "", nil, true, true},
+		{"slip.tar", makeTar([]archiveEntry{{"/etc/evil", "evil", false}}, false), Extractor{}, "", nil, true, true},
+		{"link.tar", makeTar([]archiveEntry{{"passwd", "/etc/passwd", true}, {"a.txt", "aaa", false}}, false), Extractor{}, "link", []string{"a.txt"}, false, false},
+		{"many.zip", makeZip(files), Extractor{MaxEntries: 1}, "", nil, true, true},
+		{"bomb.tar.gz", makeTar([]archiveEntry{{"zeros", strings.Repeat("0", 1<<16), false}}, true), Extractor{MaxSize: 1 << 10}, "", nil, true, true},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		naming, _ := ParseUploadNaming("", "")
+		extract := test.extract
+		h := UploadHandler(dir, "/upload", UploadOptions{Naming: naming, Extract: &extract})
+
+		r := httptest.NewRequest("PUT", "/upload/"+test.name, bytes.NewReader(test.archive))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		result := uploadResult{}
+		json.NewDecoder(w.Body).Decode(&result)
+		if result.Extracted != test.folder {
+			t.Errorf("%s: expected folder %q, got %q (%s)", test.name, test.folder, result.Extracted, result.ExtractError)
+		}
+		if (result.ExtractError != "") != test.failed {
+			t.Errorf("%s: expected failure %v, got %q", test.name, test.failed, result.ExtractError)
+		}
+		if _, err := os.Stat(filepath.Join(dir, test.name)); (err == nil) != test.kept {
+			t.Errorf("%s: expected archive kept %v, got %v", test.name, test.kept, err == nil)
+		}
+		found := []string{}
+		filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && path != filepath.Join(dir, test.name) {
+				rel, _ := filepath.Rel(filepath.Join(dir, test.folder), path)
+				found = append(found, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		if strings.Join(found, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, found)
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "evil.txt")); err == nil {
+			t.Errorf("%s: entry escaped the upload folder", test.name)
+		}
+	}
+}
+
+func TestUploadExtractKeepHook(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands are written for sh")
+	}
+
+	// the hook runs in the background, the discarded archive waits for it
+	dir := t.TempDir()
+	hook, _ := NewUploadHook(`sleep 0.2; test -f "$KNUT_UPLOAD_PATH" && touch hook-saw-archive`, time.Second, 1, HookFailKeep)
+	naming, _ := ParseUploadNaming("", "")
+	h := UploadHandler(dir, "/upload", UploadOptions{Naming: naming, Hook: hook, Extract: &Extractor{}})
+
+	r := httptest.NewRequest("PUT", "/upload/files.tar", bytes.NewReader(makeTar([]archiveEntry{{"a.txt", "aaa", false}}, false)))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d (%s)", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "files", "a.txt")); err != nil {
+		t.Errorf("expected the archive extracted: %v", err)
+	}
+
+	archive := filepath.Join(dir, "files.tar")
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if _, err := os.Stat(archive); err != nil {
+			break
+		}
+	}
+	if _, err := os.Stat(archive); err == nil {
+		t.Errorf("expected the archive discarded after the hook")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hook-saw-archive")); err != nil {
+		t.Errorf("the hook missed the archive: %v", err)
+	}
+}
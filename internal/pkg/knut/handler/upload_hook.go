@@ -0,0 +1,151 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// what happens to an upload if its hook fails
+const (
+	HookFailKeep       = "keep"       // the hook runs in the background, the file stays
+	HookFailReject     = "reject"     // the file is removed, the upload fails
+	HookFailQuarantine = "quarantine" // the file is moved to ".knut-quarantine", the upload fails
+)
+
+const quarantineDir = ".knut-quarantine"
+
+var errHookRejected = errors.New("rejected by the upload hook")
+
+// UploadHook runs 'Command' (via "sh -c", "cmd /C" on windows) for each
+// received file, inside the upload folder. a hook which waits (see
+// waits) runs on the staged file, it is moved into place only if the
+// hook succeeds. the file is described via
+//
+//	KNUT_UPLOAD_PATH   - the stored or staged file
+//	KNUT_UPLOAD_NAME   - the name the client sent
+//	KNUT_UPLOAD_SIZE   - in bytes
+//	KNUT_UPLOAD_SHA256 - hex encoded
+//	KNUT_UPLOAD_REMOTE - ip address of the client
+//
+// at most 'concurrency' hooks run at the same time, each for 'timeout' (0:
+// no limit).
+type UploadHook struct {
+	command   string
+	timeout   time.Duration
+	onFailure string
+	slots     chan struct{}
+}
+
+func NewUploadHook(command string, timeout time.Duration, concurrency int, onFailure string) (*UploadHook, error) {
+	switch onFailure {
+	case "":
+		onFailure = HookFailKeep
+	case HookFailKeep, HookFailReject, HookFailQuarantine:
+	default:
+		return nil, fmt.Errorf("invalid hook failure policy %q", onFailure)
+	}
+	return &UploadHook{
+		command:   command,
+		timeout:   timeout,
+		onFailure: onFailure,
+		slots:     make(chan struct{}, max(concurrency, 1)),
+	}, nil
+}
+
+// hookFile describes a received file for the hook
+type hookFile struct {
+	path, name, sha256, remote string
+	size                       int64
+}
+
+// waits reports if the upload waits for the hook, with "reject" and
+// "quarantine".
+func (hook *UploadHook) waits() bool {
+	return hook.onFailure != HookFailKeep
+}
+
+// start runs a hook which doesn't wait for 'file', received below 'dir',
+// in the background. 'then' follows the hook.
+func (hook *UploadHook) start(dir string, file hookFile, then func()) {
+	go func() {
+		hook.run(dir, file)
+		then()
+	}()
+}
+
+// process runs a waiting hook for 'file', received below 'dir'. it
+// returns errHookRejected if the hook failed, the file is removed or
+// quarantined then.
+func (hook *UploadHook) process(dir string, file hookFile) error {
+	err := hook.run(dir, file)
+	if err == nil {
+		return nil
+	}
+	if hook.onFailure == HookFailQuarantine {
+		quarantine := filepath.Join(dir, quarantineDir)
+		os.MkdirAll(quarantine, 0o777)
+		name := filepath.Base(file.path)
+		if file.name != "" {
+			name = sanitizeNameElement(path.Base(file.name))
+		}
+		os.Rename(file.path, filepath.Join(quarantine, strconv.FormatInt(time.Now().UnixNano(), 36)+"_"+name))
+	}
+	os.Remove(file.path) // if not quarantined
+	return fmt.Errorf("%w: %v", errHookRejected, err)
+}
+
+func (hook *UploadHook) run(dir string, file hookFile) error {
+
+	hook.slots <- struct{}{}
+	defer func() { <-hook.slots }()
+
+	ctx := context.Background()
+	if hook.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hook.timeout)
+		defer cancel()
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", hook.command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", hook.command)
+	}
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"KNUT_UPLOAD_PATH="+file.path,
+		"KNUT_UPLOAD_NAME="+file.name,
+		"KNUT_UPLOAD_SIZE="+strconv.FormatInt(file.size, 10),
+		"KNUT_UPLOAD_SHA256="+file.sha256,
+		"KNUT_UPLOAD_REMOTE="+file.remote,
+	)
+	output := bytes.NewBuffer(nil)
+	cmd.Stdout, cmd.Stderr = output, output
+	cmd.WaitDelay = time.Second // children of the shell might keep the output open
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		err = fmt.Errorf("timeout after %s", hook.timeout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: upload hook for %q: %v\n", file.path, err)
+		if out := strings.TrimSpace(output.String()); out != "" {
+			fmt.Fprintf(os.Stderr, "%s\n", out)
+		}
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadHook(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands are written for sh")
+	}
+
+	// "precious" exists before the upload, which would overwrite it
+	tests := []struct {
+		name, command, onFailure string
+		code                     int
+		stored, quarantined      bool
+	}{
+		{"ok", `test "$KNUT_UPLOAD_NAME" = ok && test "$KNUT_UPLOAD_SIZE" = 4 && test -f "$KNUT_UPLOAD_PATH"`, HookFailReject, http.StatusCreated, true, false},
+		{"staged", `test ! -e staged && test "$(cat "$KNUT_UPLOAD_PATH")" = knut`, HookFailReject, http.StatusCreated, true, false},
+		{"reject", "exit 1", HookFailReject, http.StatusUnprocessableEntity, false, false},
+		{"quarantine", "exit 1", HookFailQuarantine, http.StatusUnprocessableEntity, false, true},
+		{"keep", "exit 1", HookFailKeep, http.StatusCreated, true, false},
+		{"timeout", "sleep 5", HookFailReject, http.StatusUnprocessableEntity, false, false},
+		{"precious", "exit 1", HookFailReject, http.StatusUnprocessableEntity, true, false},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		hook, err := NewUploadHook(test.command, 500*time.Millisecond, 1, test.onFailure)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.name == "precious" {
+			os.WriteFile(filepath.Join(dir, test.name), []byte(test.name), 0o644)
+		}
+		naming, _ := ParseUploadNaming("", CollisionOverwrite)
+		h := UploadHandler(dir, "/upload", UploadOptions{Naming: naming, Hook: hook})
+
+		r := httptest.NewRequest("PUT", "/upload/"+test.name, strings.NewReader("knut"))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d, got %d (%s)", test.name, test.code, w.Code, w.Body.String())
+		}
+		if _, err := os.Stat(filepath.Join(dir, test.name)); (err == nil) != test.stored {
+			t.Errorf("%s: expected stored %v, got %v", test.name, test.stored, err == nil)
+		}
+		if data, _ := os.ReadFile(filepath.Join(dir, test.name)); test.name == "precious" && string(data) != test.name {
+			t.Errorf("%s: overwritten by a rejected upload: %q", test.name, data)
+		}
+		if held, _ := os.ReadDir(filepath.Join(dir, pendingDir)); len(held) != 0 {
+			t.Errorf("%s: expected no staged files, got %d", test.name, len(held))
+		}
+		quarantined, _ := filepath.Glob(filepath.Join(dir, quarantineDir, "*_"+test.name))
+		if (len(quarantined) == 1) != test.quarantined {
+			t.Errorf("%s: expected quarantined %v, got %v", test.name, test.quarantined, quarantined)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errUploadTooLarge = errors.New("upload exceeds the size limit")
+	errQuotaExceeded  = errors.New("upload exceeds the quota")
+	errTooManyFiles   = errors.New("too many files")
+	errTypeNotAllowed = errors.New("file type not allowed")
+)
+
+// UploadLimits restrict what an upload mapping accepts, 0 and empty lists
+// mean no restriction. 'Allow' and 'Deny' contain extensions (".jpg") and
+// MIME types ("image/png", "image/*"), the type is sniffed from the first
+// bytes of each file. all limits are enforced while the data streams in,
+// a rejected file is removed.
+type UploadLimits struct {
+	MaxFileSize    int64
+	MaxRequestSize int64
+	MaxFiles       int
+	Quota          int64 // for the whole upload folder
+	Allow          []string
+	Deny           []string
+
+	quota *uploadQuota // shared by the uploads of one mapping, see track
+}
+
+// ParseByteSize parses sizes like "512", "64k", "10M" or "2G" (base 1024).
+func ParseByteSize(s string) (int64, error) {
+	units := map[string]int64{"": 1, "k": 1 << 10, "m": 1 << 20, "g": 1 << 30, "t": 1 << 40}
+	digits := strings.TrimRight(s, "kKmMgGtTbB")
+	unit := strings.TrimSuffix(strings.ToLower(s[len(digits):]), "b")
+	factor, ok := units[unit]
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if !ok || err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if n > math.MaxInt64/factor {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return n * factor, nil
+}
+
+// checkRequest rejects requests announcing a body larger than allowed and
+// caps the body of all others.
+func (l UploadLimits) checkRequest(w http.ResponseWriter, r *http.Request) error {
+	if l.MaxRequestSize <= 0 {
+		return nil
+	}
+	if r.ContentLength > l.MaxRequestSize {
+		return errUploadTooLarge
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, l.MaxRequestSize)
+	return nil
+}
+
+// track returns the limits with the quota accounted for the upload
+// folder 'dir'.
+func (l UploadLimits) track(dir string) UploadLimits {
+	if l.Quota > 0 {
+		l.quota = &uploadQuota{dir: dir, limit: l.Quota}
+	}
+	return l
+}
+
+// remaining returns how many bytes may still be stored, -1 means
+// unlimited.
+func (l UploadLimits) remaining() int64 {
+	return l.quota.left()
+}
+
+// checkName applies the extension rules to 'name', types are checked by
+// wrap.
+func (l UploadLimits) checkName(name string) error {
+	if matchesUploadRule(l.Deny, name, "") {
+		return errTypeNotAllowed
+	}
+	return nil
+}
+
+// wrap sniffs the type of the file 'name' and applies the allow and deny
+// rules. the returned reader yields the whole content of 'src' but fails
+// beyond MaxFileSize or once the quota is used up. 'sniff' is false for
+// the later chunks of resumable uploads.
+func (l UploadLimits) wrap(name string, src io.Reader, sniff bool) (io.Reader, error) {
+
+	if err := l.checkName(name); err != nil {
+		return nil, err
+	}
+
+	if sniff && (len(l.Allow) > 0 || len(l.Deny) > 0) {
+		br := bufio.NewReaderSize(src, 512)
+		head, err := br.Peek(512)
+		if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+		ctype, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+		if matchesUploadRule(l.Deny, "", ctype) {
+			return nil, errTypeNotAllowed
+		}
+		if len(l.Allow) > 0 && !matchesUploadRule(l.Allow, name, ctype) {
+			return nil, errTypeNotAllowed
+		}
+		src = br
+	}
+
+	if l.MaxFileSize > 0 {
+		src = &limitedUploadReader{r: src, left: l.MaxFileSize, err: errUploadTooLarge}
+	}
+	if l.quota != nil {
+		src = &quotaReader{r: src, q: l.quota}
+	}
+	return src, nil
+}
+
+// matchesUploadRule reports if the extension of 'name' or 'ctype' match
+// one of the 'rules'.
+func matchesUploadRule(rules []string, name, ctype string) bool {
+	name = strings.ToLower(name)
+	for _, rule := range rules {
+		rule = strings.ToLower(rule)
+		switch {
+		case strings.HasPrefix(rule, "."):
+			if name != "" && strings.HasSuffix(name, rule) {
+				return true
+			}
+		case ctype == "":
+		case strings.HasSuffix(rule, "/*"):
+			if strings.HasPrefix(ctype, strings.TrimSuffix(rule, "*")) {
+				return true
+			}
+		case rule == ctype:
+			return true
+		}
+	}
+	return false
+}
+
+// statusForUploadError maps the errors of the upload limits and of the
+// filesystem to http status codes.
+func statusForUploadError(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, errUploadTooLarge), errors.Is(err, errTooManyFiles), errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, errQuotaExceeded):
+		return http.StatusInsufficientStorage
+	case errors.Is(err, errTypeNotAllowed):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, errHookRejected):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, errUploadRejected):
+		return http.StatusForbidden
+	}
+	return statusForFSError(err)
+}
+
+// limitedUploadReader fails with 'err' as soon as more than 'left' bytes
+// are read.
+type limitedUploadReader struct {
+	r    io.Reader
+	left int64
+	err  error
+}
+
+func (lr *limitedUploadReader) Read(p []byte) (int, error) {
+	if lr.left < 0 {
+		return 0, lr.err
+	}
+	if int64(len(p)) > lr.left+1 {
+		p = p[:lr.left+1]
+	}
+	n, err := lr.r.Read(p)
+	lr.left -= int64(n)
+	if lr.left < 0 {
+		return n, lr.err
+	}
+	return n, err
+}
+
+// the bytes stored in an upload folder are counted again after this long
+const quotaRescan = 10 * time.Second
+
+// uploadQuota counts the bytes stored below 'dir' against 'limit'. the
+// folder is walked once the count is older than quotaRescan, the bytes
+// received in between are taken as they stream in. all uploads take
+// their bytes under one lock, concurrent ones can't overrun the quota
+// together.
+type uploadQuota struct {
+	dir   string
+	limit int64
+
+	mu      sync.Mutex
+	used    int64
+	scanned time.Time
+}
+
+// left returns how many bytes may still be stored, -1 means unlimited.
+func (q *uploadQuota) left() int64 {
+	if q == nil {
+		return -1
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if time.Since(q.scanned) > quotaRescan {
+		q.used, q.scanned = diskUsage(q.dir), time.Now()
+	}
+	return max(q.limit-q.used, 0)
+}
+
+// take accounts 'n' more bytes, it fails if they exceed the quota.
+func (q *uploadQuota) take(n int64) bool {
+	if q == nil {
+		return true
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.used+n > q.limit {
+		return false
+	}
+	q.used += n
+	return true
+}
+
+// give returns the 'n' bytes of a removed file.
+func (q *uploadQuota) give(n int64) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	q.used = max(q.used-n, 0)
+	q.mu.Unlock()
+}
+
+// diskUsage sums the sizes of the regular files below 'dir'.
+func diskUsage(dir string) int64 {
+	used := int64(0)
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if fi, err := d.Info(); err == nil {
+				used += fi.Size()
+			}
+		}
+		return nil
+	})
+	return used
+}
+
+// quotaReader takes each chunk read from 'r' from 'q' and fails with
+// errQuotaExceeded once it is used up. all taken bytes are given back
+// when reading fails: the caller drops what it stored, or the next scan
+// counts what it keeps.
+type quotaReader struct {
+	r     io.Reader
+	q     *uploadQuota
+	taken int64
+}
+
+func (qr *quotaReader) Read(p []byte) (int, error) {
+	n, err := qr.r.Read(p)
+	if err == nil || err == io.EOF {
+		if qr.q.take(int64(n)) {
+			qr.taken += int64(n)
+			return n, err
+		}
+		err = errQuotaExceeded
+	}
+	qr.q.give(qr.taken)
+	qr.taken = 0
+	return n, err
+}
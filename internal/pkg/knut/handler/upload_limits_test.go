@@ -0,0 +1,129 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected int64
+		fails    bool
+	}{
+		{"512", 512, false},
+		{"64k", 64 << 10, false},
+		{"10M", 10 << 20, false},
+		{"2GB", 2 << 30, false},
+		{"1x", 0, true},
+		{"-1", 0, true},
+		{"8388607T", 8388607 << 40, false},
+		{"8388608T", 0, true},
+		{"99999999999T", 0, true},
+		{"", 0, true},
+	}
+	for _, test := range tests {
+		n, err := ParseByteSize(test.in)
+		if (err != nil) != test.fails || n != test.expected {
+			t.Errorf("%q: expected %d (fails %v), got %d (%v)", test.in, test.expected, test.fails, n, err)
+		}
+	}
+}
+
+func TestUploadLimits(t *testing.T) {
+
+	dir := t.TempDir()
+	naming, _ := ParseUploadNaming("", "")
+	limits := UploadLimits{
+		MaxFileSize: 16,
+		Quota:       36,
+		Allow:       []string{"text/*", ".bin"},
+		Deny:        []string{".exe"},
+	}
+	h := UploadHandler(dir, "/upload", UploadOptions{Naming: naming, Limits: limits})
+
+	steps := []struct {
+		name, body string
+		code       int
+	}{
+		{"a.txt", "knut", http.StatusCreated},
+		{"b.txt", strings.Repeat("x", 17), http.StatusRequestEntityTooLarge},
+		{"c.exe", "knut", http.StatusUnsupportedMediaType},
+		{"d.png", "\x89PNG\r\n\x1a\n", http.StatusUnsupportedMediaType},
+		{"e.bin", "\x00\x01\x02", http.StatusCreated},
+		{"f.txt", strings.Repeat("x", 16), http.StatusCreated},
+		{"g.txt", strings.Repeat("x", 16), http.StatusInsufficientStorage},
+	}
+
+	for _, step := range steps {
+		r := httptest.NewRequest("PUT", "/upload/"+step.name, strings.NewReader(step.body))
+		r.ContentLength = -1 // streamed, unknown size
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != step.code {
+			t.Errorf("%s: expected %d, got %d (%s)", step.name, step.code, w.Code, w.Body.String())
+		}
+		_, err := os.Stat(filepath.Join(dir, step.name))
+		if stored := err == nil; stored != (step.code == http.StatusCreated) {
+			t.Errorf("%s: expected stored %v, got %v", step.name, step.code == http.StatusCreated, stored)
+		}
+	}
+}
+
+func TestUploadLimitsConcurrentQuota(t *testing.T) {
+
+	dir := t.TempDir()
+	naming, _ := ParseUploadNaming("", "")
+	h := UploadHandler(dir, "/upload", UploadOptions{Naming: naming, Limits: UploadLimits{Quota: 30}})
+
+	// both uploads fit the quota on their own and are half way through
+	// before either one completes.
+	names := []string{"a.txt", "b.txt"}
+	writers := make([]*io.PipeWriter, len(names))
+	codes := make([]int, len(names))
+	wg := sync.WaitGroup{}
+	for i, name := range names {
+		pr, pw := io.Pipe()
+		writers[i] = pw
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := httptest.NewRequest("PUT", "/upload/"+name, pr)
+			r.ContentLength = -1
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			codes[i] = w.Code
+			pr.CloseWithError(io.ErrClosedPipe)
+		}()
+	}
+	for _, pw := range writers {
+		pw.Write([]byte(strings.Repeat("x", 15)))
+	}
+	for _, pw := range writers {
+		pw.Write([]byte(strings.Repeat("x", 5)))
+		pw.Close()
+	}
+	wg.Wait()
+
+	stored := int64(0)
+	for i, name := range names {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if codes[i] == http.StatusCreated {
+			stored += fi.Size()
+		} else if err == nil {
+			t.Errorf("%s: rejected with %d but stored", name, codes[i])
+		}
+	}
+	if stored > 30 || codes[0] == http.StatusCreated && codes[1] == http.StatusCreated {
+		t.Errorf("quota 30 overrun: %v, %d bytes stored", codes, stored)
+	}
+}
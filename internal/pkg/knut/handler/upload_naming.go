@@ -0,0 +1,261 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// the collision policies of UploadNaming
+const (
+	CollisionSuffix    = "suffix"    // "a.txt" becomes "a-1.txt", "a-2.txt", ...
+	CollisionOverwrite = "overwrite" // replace the existing file
+	CollisionReject    = "reject"    // refuse the upload
+)
+
+// naming presets
+var namingPresets = map[string]string{
+	"original": "{name}",
+	"random":   "{ip}_{port}_{name}_{rand}",
+}
+
+// UploadNaming decides the names of uploaded files: 'Template' builds the
+// name from the fields
+//
+//	{name} {base} {ext} - original filename, without and only its extension
+//	{date} {time}       - time of the upload (2006-01-02, 150405)
+//	{ip} {port}         - the remote address
+//	{rand}              - 8 random hex digits
+//
+// a "/" in the template creates folders. every path element is sanitised.
+// 'Collision' decides what happens if the name exists already.
+type UploadNaming struct {
+	Template  string
+	Collision string
+}
+
+// ParseUploadNaming accepts a template or a preset ("original", "random")
+// and a collision policy, empty values select "original" and "suffix".
+func ParseUploadNaming(naming, collision string) (UploadNaming, error) {
+	un := UploadNaming{Template: "{name}", Collision: CollisionSuffix}
+	if preset, ok := namingPresets[naming]; ok {
+		un.Template = preset
+	} else if naming != "" {
+		if !strings.Contains(naming, "{") {
+			return un, fmt.Errorf("invalid naming %q, expected %q, %q or a template", naming, "original", "random")
+		}
+		un.Template = naming
+	}
+	switch collision {
+	case "":
+	case CollisionSuffix, CollisionOverwrite, CollisionReject:
+		un.Collision = collision
+	default:
+		return un, fmt.Errorf("invalid collision policy %q", collision)
+	}
+	return un, nil
+}
+
+// Name expands the template for the file 'original', uploaded from
+// 'remoteAddr' at 'now'. the result is a sanitised, relative, slash
+// separated name.
+func (un UploadNaming) Name(original, remoteAddr string, now time.Time) string {
+
+	original = sanitizeNameElement(path.Base(strings.ReplaceAll(original, "\\", "/")))
+	ext := path.Ext(original)
+	host, port, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	rnd := make([]byte, 4)
+	rand.Read(rnd)
+
+	name := strings.NewReplacer(
+		"{name}", original,
+		"{base}", strings.TrimSuffix(original, ext),
+		"{ext}", ext,
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("150405"),
+		"{ip}", host,
+		"{port}", port,
+		"{rand}", hex.EncodeToString(rnd),
+	).Replace(un.Template)
+
+	elements := []string{}
+	for _, element := range strings.Split(name, "/") {
+		if element == "" || element == "." || element == ".." {
+			continue
+		}
+		elements = append(elements, sanitizeNameElement(element))
+	}
+	if len(elements) == 0 {
+		return "upload"
+	}
+	return strings.Join(elements, "/")
+}
+
+// PathName is Name for files uploaded as part of a folder: the folders of
+// the relative path 'original' are kept (sanitised, ".." dropped) in front
+// of the expanded template.
+func (un UploadNaming) PathName(original, remoteAddr string, now time.Time) string {
+	elements := []string{}
+	folders := path.Dir(strings.ReplaceAll(original, "\\", "/"))
+	for _, element := range strings.Split(folders, "/") {
+		if element == "" || element == "." || element == ".." {
+			continue
+		}
+		elements = append(elements, sanitizeNameElement(element))
+	}
+	return path.Join(append(elements, un.Name(original, remoteAddr, now))...)
+}
+
+// Store writes 'src' below 'dir' as 'name' (as returned by Name), obeying
+// the collision policy. 'beforeOverwrite' is called before an existing
+// file is replaced, it might be nil. the stored name, relative to 'dir',
+// is returned.
+func (un UploadNaming) Store(dir, name string, src io.Reader, beforeOverwrite func(string) error) (string, int64, error) {
+
+	target, err := containedPath(dir, name)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o777); err != nil {
+		return "", 0, err
+	}
+
+	switch un.Collision {
+	case CollisionOverwrite:
+		if beforeOverwrite != nil {
+			if err := beforeOverwrite(target); err != nil {
+				return "", 0, err
+			}
+		}
+		n, err := writeFileAtomic(target, src)
+		return name, n, err
+	case CollisionReject:
+		n, err := storeExclusive(target, src)
+		return name, n, err
+	}
+
+	ext := filepath.Ext(target)
+	base := strings.TrimSuffix(target, ext)
+	candidate := target
+	for i := 1; ; i++ {
+		n, err := storeExclusive(candidate, src)
+		if !errors.Is(err, os.ErrExist) {
+			rel, _ := filepath.Rel(dir, candidate)
+			return filepath.ToSlash(rel), n, err
+		}
+		candidate = base + "-" + strconv.Itoa(i) + ext
+	}
+}
+
+// Place moves the file 'staged' below 'dir' as 'name', obeying the
+// collision policy like Store, and returns its local filename. the
+// target is claimed via an exclusively created placeholder first, files
+// placed at the same time can't replace each other.
+func (un UploadNaming) Place(dir, name, staged string, beforeOverwrite func(string) error) (string, error) {
+
+	target, err := containedPath(dir, name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o777); err != nil {
+		return "", err
+	}
+
+	if un.Collision == CollisionOverwrite {
+		if beforeOverwrite != nil {
+			if err := beforeOverwrite(target); err != nil {
+				return "", err
+			}
+		}
+		return target, os.Rename(staged, target)
+	}
+
+	ext := filepath.Ext(target)
+	base := strings.TrimSuffix(target, ext)
+	candidate := target
+	for i := 1; ; i++ {
+		f, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+		if err == nil {
+			f.Close()
+			if err = os.Rename(staged, candidate); err != nil {
+				os.Remove(candidate)
+			}
+			return candidate, err
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return "", err
+		}
+		if un.Collision == CollisionReject {
+			return "", fmt.Errorf("%q: %w", name, os.ErrExist)
+		}
+		candidate = base + "-" + strconv.Itoa(i) + ext
+	}
+}
+
+// Target returns the local filename for 'name' obeying the collision
+// policy without writing anything: the first free suffixed name for
+// "suffix", an error for an existing file and "reject". used when the
+// content is already on disk and just needs to be renamed.
+func (un UploadNaming) Target(dir, name string) (string, error) {
+	target, err := containedPath(dir, name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o777); err != nil {
+		return "", err
+	}
+	if _, err := os.Lstat(target); err != nil || un.Collision == CollisionOverwrite {
+		return target, nil
+	}
+	if un.Collision == CollisionReject {
+		return "", fmt.Errorf("%q: %w", name, os.ErrExist)
+	}
+	ext := filepath.Ext(target)
+	base := strings.TrimSuffix(target, ext)
+	for i := 1; ; i++ {
+		candidate := base + "-" + strconv.Itoa(i) + ext
+		if _, err := os.Lstat(candidate); err != nil {
+			return candidate, nil
+		}
+	}
+}
+
+// sanitizeNameElement turns 's' into a harmless filename: no separators,
+// control or reserved characters, not hidden, at most 200 bytes.
+func sanitizeNameElement(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) || r == utf8.RuneError {
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.TrimSpace(strings.TrimRight(s, ". "))
+	if strings.HasPrefix(s, ".") {
+		s = "_" + s[1:]
+	}
+	for len(s) > 200 {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	if s == "" {
+		return "upload"
+	}
+	return s
+}
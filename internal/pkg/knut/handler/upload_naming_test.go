@@ -0,0 +1,131 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUploadNamingName(t *testing.T) {
+
+	now := time.Date(2026, 10, 19, 13, 14, 15, 0, time.UTC)
+	tests := []struct {
+		template, original, remote string
+		expected                   string
+	}{
+		{"{name}", "report.pdf", "1.2.3.4:5678", "report.pdf"},
+		{"{name}", "../../etc/passwd", "1.2.3.4:5678", "passwd"},
+		{"{name}", `C:\Users\knut\a.txt`, "1.2.3.4:5678", "a.txt"},
+		{"{name}", ".bashrc", "1.2.3.4:5678", "_bashrc"},
+		{"{name}", "a\x00b<c>.txt", "1.2.3.4:5678", "a_b_c_.txt"},
+		{"{name}", "..", "1.2.3.4:5678", "upload"},
+		{"{date}/{ip}_{base}{ext}", "a.tar.gz", "1.2.3.4:5678", "2026-10-19/1.2.3.4_a.tar.gz"},
+		{"{date}/{time}_{name}", "a.txt", "[::1]:5678", "2026-10-19/131415_a.txt"},
+		{"{ip}/{name}", "a.txt", "[::1]:5678", "__1/a.txt"},
+		{"../{name}", "a.txt", "1.2.3.4:5678", "a.txt"},
+	}
+
+	for _, test := range tests {
+		un := UploadNaming{Template: test.template}
+		if name := un.Name(test.original, test.remote, now); name != test.expected {
+			t.Errorf("%q with %q: expected %q, got %q", test.template, test.original, test.expected, name)
+		}
+	}
+}
+
+func TestUploadNamingStore(t *testing.T) {
+
+	dir := t.TempDir()
+	tests := []struct {
+		collision, expected, content string
+		fails                        bool
+	}{
+		{CollisionSuffix, "a.txt", "1", false},
+		{CollisionSuffix, "a-1.txt", "2", false},
+		{CollisionSuffix, "a-2.txt", "3", false},
+		{CollisionReject, "a.txt", "4", true},
+		{CollisionOverwrite, "a.txt", "5", false},
+	}
+
+	for _, test := range tests {
+		un := UploadNaming{Template: "{name}", Collision: test.collision}
+		name, _, err := un.Store(dir, "a.txt", strings.NewReader(test.content), nil)
+		if (err != nil) != test.fails {
+			t.Errorf("%s: expected failure %v, got %v", test.collision, test.fails, err)
+			continue
+		}
+		if test.fails {
+			continue
+		}
+		if name != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.collision, test.expected, name)
+		}
+		if data, _ := os.ReadFile(filepath.Join(dir, name)); string(data) != test.content {
+			t.Errorf("%s: expected content %q, got %q", test.collision, test.content, data)
+		}
+	}
+}
+
+func TestUploadNamingPlace(t *testing.T) {
+
+	// staged files approved at the same time, placed as the same name
+	const n = 16
+	tests := []struct {
+		collision string
+		placed    int
+	}{
+		{CollisionSuffix, n},
+		{CollisionReject, 1},
+		{CollisionOverwrite, n},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		un := UploadNaming{Template: "{name}", Collision: test.collision}
+		targets := make([]string, n)
+		wg := sync.WaitGroup{}
+		for i := range n {
+			staged := filepath.Join(dir, fmt.Sprintf("staged-%d", i))
+			os.WriteFile(staged, []byte(strconv.Itoa(i)), 0o644)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				target, err := un.Place(dir, "a.txt", staged, nil)
+				if err == nil {
+					targets[i] = target
+				} else if !errors.Is(err, os.ErrExist) {
+					t.Errorf("%s: %v", test.collision, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		placed, kept := 0, map[string]bool{}
+		for i, target := range targets {
+			if target == "" {
+				continue
+			}
+			placed++
+			data, _ := os.ReadFile(target)
+			if test.collision != CollisionOverwrite && string(data) != strconv.Itoa(i) {
+				t.Errorf("%s: %s was replaced, expected %d, got %q", test.collision, target, i, data)
+			}
+			kept[target] = true
+		}
+		if placed != test.placed {
+			t.Errorf("%s: expected %d placed, got %d", test.collision, test.placed, placed)
+		}
+		if test.collision == CollisionSuffix && len(kept) != n {
+			t.Errorf("%s: expected %d distinct files, got %d", test.collision, n, len(kept))
+		}
+	}
+}
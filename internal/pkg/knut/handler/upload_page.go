@@ -0,0 +1,238 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"html/template"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// who gets to see the recently received files on the upload page
+const (
+	RecentOff      = "off"
+	RecentOperator = "operator" // only direct requests from the local machine, see showRecent
+	RecentAll      = "all"
+)
+
+const maxRecentUploads = 50
+
+type recentUpload struct {
+	Name   string    `json:"name"`
+	Size   int64     `json:"size"`
+	Time   time.Time `json:"time"`
+	Remote string    `json:"remote"`
+}
+
+// uploadHistory keeps the most recently received files in memory.
+type uploadHistory struct {
+	mu      sync.Mutex
+	entries []recentUpload
+}
+
+func (h *uploadHistory) add(result uploadResult, remoteAddr string) {
+	if result.Error != "" {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry := recentUpload{Name: result.Name, Size: result.Size, Time: time.Now(), Remote: remoteIP(remoteAddr)}
+	h.entries = append([]recentUpload{entry}, h.entries...)
+	if len(h.entries) > maxRecentUploads {
+		h.entries = h.entries[:maxRecentUploads]
+	}
+}
+
+// list returns the recent uploads, newest first.
+func (h *uploadHistory) list() []recentUpload {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]recentUpload{}, h.entries...)
+}
+
+// showRecent reports if 'r' may see the recent uploads. the operator is
+// a loopback peer whose request carries no forwarding headers. a relay
+// on the local machine that adds none of them (ssh -L, stunnel, socat)
+// turns every client into the operator, such setups need -auth or
+// RecentOff.
+func showRecent(policy string, r *http.Request) bool {
+	switch policy {
+	case RecentAll:
+		return true
+	case RecentOperator:
+		for _, name := range []string{"Forwarded", "X-Forwarded-For", "X-Real-Ip"} {
+			if r.Header.Get(name) != "" {
+				return false
+			}
+		}
+		ip := net.ParseIP(remoteIP(r.RemoteAddr))
+		return ip != nil && ip.IsLoopback()
+	}
+	return false
+}
+
+type uploadPage struct {
+	Posted     bool
+	Results    []uploadResult
+	Received   int64
+	Duration   time.Duration
+	ShowRecent bool
+	Recent     []recentUpload
+}
+
+// uploadPageTmpl works without javascript: the form posts all selected
+// files at once and the page is rendered again with the results. with
+// javascript, each file is posted on its own to show its progress.
+var uploadPageTmpl = template.Must(template.New("upload").Parse(`<!doctype html>
+<html>
+<head>
+	<title>knut - file upload</title>
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<style type="text/css">
+* { font-family: monospace }
+input[type="submit"] { margin-top: 1em }
+td { padding: 0.1em 1em 0.1em 0 }
+#drop { border: 2px dashed #aaa; padding: 2em 1em; margin: 1em 0; text-align: center }
+#drop.over { border-color: #333; background: #eee }
+#queue { list-style: none; padding: 0 }
+#queue li { margin: 0.3em 0 }
+#queue progress { width: 20em; vertical-align: middle }
+.error { color: #b00 }
+	</style>
+</head>
+<body>
+<h1>knut - file upload</h1>
+{{- if .Posted }}
+<p>ok, received {{ .Received }} bytes over {{ .Duration }}</p>
+<table>
+{{- range .Results }}
+<tr><td>{{ .Name }}</td><td>{{ .Size }}</td><td>{{ if .Error }}<span class="error">error: {{ .Error }}</span>{{ else }}{{ .SHA256 }}{{ end }}{{ if .Extracted }} - extracted to {{ .Extracted }}{{ end }}{{ if .ExtractError }} <span class="error">not extracted: {{ .ExtractError }}</span>{{ end }}</td></tr>
+{{- end }}
+</table>
+{{- end }}
+<form method="post" enctype="multipart/form-data" id="form">
+	<div id="drop">
+		drop files or folders here
+		<div>files: <input type="file" name="upload_file" multiple></div>
+		<div>folder: <input type="file" name="upload_dir" webkitdirectory multiple></div>
+	</div>
+	<div>
+		<input type="submit" value="Upload">
+	</div>
+</form>
+<ul id="queue"></ul>
+{{- if .ShowRecent }}
+<h2>recently received</h2>
+<table id="recent">
+{{- range .Recent }}
+<tr><td>{{ .Time.Format "15:04:05" }}</td><td>{{ .Name }}</td><td>{{ .Size }}</td><td>{{ .Remote }}</td></tr>
+{{- else }}
+<tr><td>nothing yet</td></tr>
+{{- end }}
+</table>
+{{- end }}
+<script>
+(function() {
+	var form = document.getElementById("form"), drop = document.getElementById("drop"),
+		queue = document.getElementById("queue"), pending = [], running = 0;
+
+	function next() {
+		while (running < 3 && pending.length > 0) { start(pending.shift()); }
+		if (running === 0 && pending.length === 0) { refreshRecent(); }
+	}
+
+	function enqueue(file, path) {
+		var li = document.createElement("li"), bar = document.createElement("progress"),
+			label = document.createElement("span"), cancel = document.createElement("button");
+		bar.max = file.size || 1; bar.value = 0;
+		label.textContent = " " + path + " ";
+		cancel.textContent = "cancel";
+		li.append(bar, label, cancel);
+		queue.append(li);
+		var job = { file: file, path: path, bar: bar, label: label, cancel: cancel, xhr: null };
+		cancel.onclick = function() {
+			var i = pending.indexOf(job);
+			if (i >= 0) { pending.splice(i, 1); done(job, "canceled", true); return; }
+			if (job.xhr) { job.xhr.abort(); }
+		};
+		pending.push(job);
+	}
+
+	function done(job, text, failed) {
+		job.label.textContent = " " + job.path + " - " + text;
+		job.label.className = failed ? "error" : "";
+		job.cancel.remove();
+	}
+
+	function start(job) {
+		running++;
+		var xhr = job.xhr = new XMLHttpRequest(), body = new FormData();
+		body.append("upload_file", job.file, job.path);
+		xhr.open("POST", location.pathname);
+		xhr.setRequestHeader("Accept", "application/json");
+		xhr.upload.onprogress = function(ev) { job.bar.value = ev.loaded; };
+		xhr.onload = function() {
+			var result = {};
+			try { result = JSON.parse(xhr.responseText)[0] || {}; } catch (e) { result.error = xhr.statusText; }
+			if (result.error) { done(job, "error: " + result.error, true); }
+			else { job.bar.value = job.bar.max; done(job, "ok, stored as " + result.name +
+				(result.extracted ? ", extracted to " + result.extracted : "") +
+				(result.extract_error ? ", not extracted: " + result.extract_error : "")); }
+		};
+		xhr.onerror = function() { done(job, "failed", true); };
+		xhr.onabort = function() { done(job, "canceled", true); };
+		xhr.onloadend = function() { running--; next(); };
+		xhr.send(body);
+	}
+
+	function refreshRecent() {
+		var recent = document.getElementById("recent");
+		if (!recent) { return; }
+		fetch(location.pathname).then(function(resp) { return resp.text(); }).then(function(html) {
+			var doc = new DOMParser().parseFromString(html, "text/html"), fresh = doc.getElementById("recent");
+			if (fresh) { recent.replaceWith(fresh); }
+		});
+	}
+
+	function walk(entry, path) {
+		if (entry.isFile) {
+			entry.file(function(file) { enqueue(file, path + file.name); next(); });
+		} else if (entry.isDirectory) {
+			var reader = entry.createReader();
+			(function read() {
+				reader.readEntries(function(entries) {
+					if (entries.length === 0) { return; }
+					entries.forEach(function(e) { walk(e, path + entry.name + "/"); });
+					read();
+				});
+			})();
+		}
+	}
+
+	form.addEventListener("submit", function(ev) {
+		ev.preventDefault();
+		Array.prototype.forEach.call(form.querySelectorAll("input[type=file]"), function(input) {
+			Array.prototype.forEach.call(input.files, function(f) { enqueue(f, f.webkitRelativePath || f.name); });
+			input.value = "";
+		});
+		next();
+	});
+	drop.addEventListener("dragover", function(ev) { ev.preventDefault(); drop.classList.add("over"); });
+	drop.addEventListener("dragleave", function() { drop.classList.remove("over"); });
+	drop.addEventListener("drop", function(ev) {
+		ev.preventDefault();
+		drop.classList.remove("over");
+		Array.prototype.forEach.call(ev.dataTransfer.items, function(item) {
+			var entry = item.webkitGetAsEntry && item.webkitGetAsEntry();
+			if (entry) { walk(entry, ""); } else if (item.kind === "file") { var f = item.getAsFile(); enqueue(f, f.name); }
+		});
+		next();
+	});
+})();
+</script>
+</body>
+</html>
+`))
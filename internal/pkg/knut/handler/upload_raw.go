@@ -0,0 +1,272 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// partSuffix marks the partially received files of resumable uploads
+const partSuffix = ".knut-part"
+
+// uploadResult is the JSON answer to a raw upload
+type uploadResult struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256,omitempty"`
+	Complete bool   `json:"complete"`
+	Error    string `json:"error,omitempty"`
+
+	Extracted    string `json:"extracted,omitempty"` // folder the archive was unpacked to
+	ExtractError string `json:"extract_error,omitempty"`
+
+	status int    // of a failed upload
+	staged string // the received file while it waits to be moved into place
+}
+
+// isRawUpload reports if 'r' carries the file as its plain body instead
+// of multipart encoded form data.
+func isRawUpload(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPut:
+		return true
+	case http.MethodPost:
+		ctype, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		return ctype != "multipart/form-data"
+	}
+	return false
+}
+
+// rawUploader stores the bodies of PUT and non-multipart POST requests to
+// "uri/<name>" below "dir", named via opts.Naming. the body is streamed
+// to disk. requests with a "Content-Range" header append to
+// "dir/.<name>-<hash of the path>.knut-part" until the last byte arrives, a mismatching start
+// offset is answered with "416 Range Not Satisfiable" and the number of
+// bytes received so far.
+type rawUploader struct {
+	dir, uri string
+	opts     UploadOptions
+	history  *uploadHistory
+
+	inFlight sync.Map // names of the resumable uploads being written
+}
+
+func (ru *rawUploader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(ru.uri, "/")), "/")
+	if name == "" {
+		if _, params, err := mime.ParseMediaType(r.Header.Get("Content-Disposition")); err == nil {
+			name = params["filename"]
+		}
+	}
+	if err := ru.opts.Limits.checkRequest(w, r); err != nil {
+		ru.reply(w, statusForUploadError(err), uploadResult{Name: name, Error: err.Error()})
+		return
+	}
+
+	if cr := r.Header.Get("Content-Range"); cr != "" {
+		if name == "" {
+			ru.reply(w, http.StatusBadRequest, uploadResult{Error: "resumable uploads need a name"})
+			return
+		}
+		start, end, total, err := parseContentRange(cr)
+		if err != nil {
+			ru.reply(w, http.StatusBadRequest, uploadResult{Name: name, Error: err.Error()})
+			return
+		}
+		ru.storeRange(w, r, name, start, end, total)
+		return
+	}
+
+	ru.store(w, r, name)
+}
+
+// store writes the whole body into the file named after 'name'.
+func (ru *rawUploader) store(w http.ResponseWriter, r *http.Request, name string) {
+
+	src, err := ru.opts.Limits.wrap(name, r.Body, true)
+	if err != nil {
+		ru.reply(w, statusForUploadError(err), uploadResult{Name: name, Error: err.Error()})
+		return
+	}
+	h := sha256.New()
+	body := io.TeeReader(src, h)
+
+	original := name
+	name = ru.opts.Naming.Name(name, r.RemoteAddr, time.Now())
+	result, err := ru.opts.store(ru.dir, name, body)
+	if err != nil {
+		ru.reply(w, statusForUploadError(err), uploadResult{Name: result.Name, Size: result.Size, Error: err.Error()})
+		return
+	}
+	result.SHA256, result.Complete = hex.EncodeToString(h.Sum(nil)), true
+	ru.finish(w, r, original, result)
+}
+
+// finish moves the stored file into place, see UploadOptions.finish,
+// and replies.
+func (ru *rawUploader) finish(w http.ResponseWriter, r *http.Request, original string, result uploadResult) {
+	ru.opts.finish(r.Context(), ru.dir, original, r.RemoteAddr, &result)
+	if result.Error != "" {
+		ru.reply(w, result.status, result)
+		return
+	}
+	ru.history.add(result, r.RemoteAddr)
+	ru.reply(w, http.StatusCreated, result)
+}
+
+// storeRange appends the body to the partial file of 'name', 'start' must
+// match what was received so far. 'total' is -1 if unknown.
+func (ru *rawUploader) storeRange(w http.ResponseWriter, r *http.Request, name string, start, end, total int64) {
+
+	naming, limits := ru.opts.Naming, ru.opts.Limits
+	if limits.MaxFileSize > 0 && (total > limits.MaxFileSize || end >= limits.MaxFileSize) {
+		ru.reply(w, http.StatusRequestEntityTooLarge, uploadResult{Name: name, Error: errUploadTooLarge.Error()})
+		return
+	}
+	part := ru.partName(name)
+
+	if _, loaded := ru.inFlight.LoadOrStore(part, true); loaded {
+		ru.reply(w, http.StatusConflict, uploadResult{Name: name, Error: "upload in progress"})
+		return
+	}
+	defer ru.inFlight.Delete(part)
+
+	// fail early instead of after the last byte
+	if naming.Collision == CollisionReject {
+		if _, err := naming.Target(ru.dir, naming.Name(name, r.RemoteAddr, time.Now())); err != nil {
+			ru.reply(w, statusForFSError(err), uploadResult{Name: name, Error: err.Error()})
+			return
+		}
+	}
+
+	received := int64(0)
+	if fi, err := os.Stat(part); err == nil {
+		received = fi.Size()
+	}
+	if start != received {
+		if received > 0 {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", received-1))
+		}
+		ru.reply(w, http.StatusRequestedRangeNotSatisfiable, uploadResult{
+			Name: name, Size: received, Error: fmt.Sprintf("expected offset %d", received),
+		})
+		return
+	}
+
+	src, err := limits.wrap(name, r.Body, start == 0)
+	if err != nil {
+		ru.reply(w, statusForUploadError(err), uploadResult{Name: name, Size: received, Error: err.Error()})
+		return
+	}
+	f, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o666)
+	if err != nil {
+		ru.reply(w, statusForFSError(err), uploadResult{Name: name, Error: err.Error()})
+		return
+	}
+	n, err := io.CopyN(f, src, end-start+1)
+	if err != nil {
+		f.Truncate(received) // keep the chunks received so far
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		code := statusForUploadError(err)
+		if errors.Is(err, io.EOF) {
+			code, err = http.StatusBadRequest, fmt.Errorf("body ended after %d of %d bytes", n, end-start+1)
+		}
+		ru.reply(w, code, uploadResult{Name: name, Size: received, Error: err.Error()})
+		return
+	}
+	received += n
+	AddLogNote(r, "upload %q (bytes %d-%d)", name, start, end)
+
+	if total < 0 || received < total {
+		ru.reply(w, http.StatusAccepted, uploadResult{Name: name, Size: received})
+		return
+	}
+
+	sum, err := sha256File(part)
+	if err != nil {
+		ru.reply(w, statusForFSError(err), uploadResult{Name: name, Size: received, Error: err.Error()})
+		return
+	}
+	result := uploadResult{Name: naming.Name(name, r.RemoteAddr, time.Now()), Size: received, SHA256: sum, Complete: true, staged: part}
+	ru.finish(w, r, name, result)
+}
+
+// partName is the partial file of the resumable upload 'name'. uploads
+// of the same base name into different folders get parts of their own.
+func (ru *rawUploader) partName(name string) string {
+	sum := sha256.Sum256([]byte(path.Clean("/" + name)))
+	return filepath.Join(ru.dir, "."+sanitizeNameElement(path.Base(name))+"-"+hex.EncodeToString(sum[:6])+partSuffix)
+}
+
+func (ru *rawUploader) reply(w http.ResponseWriter, code int, result uploadResult) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(result)
+}
+
+// parseContentRange parses "bytes <start>-<end>/<total>", total is -1 for
+// "*".
+func parseContentRange(s string) (start, end, total int64, err error) {
+	invalid := fmt.Errorf("invalid Content-Range %q", s)
+	spec, found := strings.CutPrefix(s, "bytes ")
+	if !found {
+		return 0, 0, 0, invalid
+	}
+	r, t, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, 0, invalid
+	}
+	first, last, found := strings.Cut(r, "-")
+	if !found {
+		return 0, 0, 0, invalid
+	}
+	if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+		return 0, 0, 0, invalid
+	}
+	if end, err = strconv.ParseInt(last, 10, 64); err != nil {
+		return 0, 0, 0, invalid
+	}
+	total = -1
+	if t != "*" {
+		if total, err = strconv.ParseInt(t, 10, 64); err != nil {
+			return 0, 0, 0, invalid
+		}
+	}
+	if start < 0 || end < start || (total >= 0 && end >= total) {
+		return 0, 0, 0, invalid
+	}
+	return start, end, total, nil
+}
+
+func sha256File(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
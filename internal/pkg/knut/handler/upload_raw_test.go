@@ -0,0 +1,78 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRawUpload(t *testing.T) {
+
+	dir := t.TempDir()
+	naming, _ := ParseUploadNaming("original", CollisionReject)
+	h := UploadHandler(dir, "/upload", UploadOptions{Naming: naming})
+
+	do := func(method, path, body string, hdr ...string) (int, uploadResult) {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		for i := 0; i+1 < len(hdr); i += 2 {
+			r.Header.Set(hdr[i], hdr[i+1])
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		result := uploadResult{}
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return w.Code, result
+	}
+
+	steps := []struct {
+		method, path, body string
+		hdr                []string
+		code               int
+		size               int64
+	}{
+		{"PUT", "/upload/a.txt", "knut", nil, http.StatusCreated, 4},
+		{"PUT", "/upload/a.txt", "knut", nil, http.StatusConflict, 0},
+		{"POST", "/upload/b.txt", "knut", []string{"Content-Type", "text/plain"}, http.StatusCreated, 4},
+		{"PUT", "/upload/.hidden", "knut", nil, http.StatusCreated, 4},
+		{"PUT", "/upload/c.txt", "kn", []string{"Content-Range", "bytes 0-1/4"}, http.StatusAccepted, 2},
+		{"PUT", "/upload/c.txt", "kn", []string{"Content-Range", "bytes 0-1/4"}, http.StatusRequestedRangeNotSatisfiable, 2},
+		{"PUT", "/upload/c.txt", "ut", []string{"Content-Range", "bytes 2-3/4"}, http.StatusCreated, 4},
+		{"PUT", "/upload/d.txt", "knut", []string{"Content-Range", "bytes 3-1/4"}, http.StatusBadRequest, 0},
+		// same base name, different folders: parts of their own
+		{"PUT", "/upload/f/x.txt", "kn", []string{"Content-Range", "bytes 0-1/4"}, http.StatusAccepted, 2},
+		{"PUT", "/upload/g/x.txt", "kn", []string{"Content-Range", "bytes 0-1/4"}, http.StatusAccepted, 2},
+		{"PUT", "/upload/f/x.txt", "ut", []string{"Content-Range", "bytes 2-3/4"}, http.StatusCreated, 4},
+		{"PUT", "/upload/g/x.txt", "ut", []string{"Content-Range", "bytes 2-3/4"}, http.StatusConflict, 0}, // "x.txt" exists
+	}
+
+	for i, step := range steps {
+		code, result := do(step.method, step.path, step.body, step.hdr...)
+		if code != step.code || result.Size != step.size {
+			t.Errorf("step %d: %s %s expected %d/%d, got %d/%d (%s)",
+				i, step.method, step.path, step.code, step.size, code, result.Size, result.Error)
+		}
+	}
+
+	sum := sha256.Sum256([]byte("knut"))
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "_hidden", "x.txt"} {
+		if data, _ := os.ReadFile(filepath.Join(dir, name)); string(data) != "knut" {
+			t.Errorf("expected %q to contain %q, got %q", name, "knut", data)
+		}
+	}
+	if _, result := do("PUT", "/upload/e.txt", "knut"); result.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("expected sha256 %x, got %s", sum, result.SHA256)
+	}
+	// only the part of the rejected "g/x.txt" is left, for a retry
+	if parts, _ := filepath.Glob(filepath.Join(dir, "*"+partSuffix)); len(parts) != 1 {
+		t.Errorf("expected one partial file, got %q", parts)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUploadFolder(t *testing.T) {
+
+	dir := t.TempDir()
+	naming, _ := ParseUploadNaming("", "")
+	h := UploadHandler(dir, "/upload", UploadOptions{Naming: naming})
+
+	files := []struct{ name, stored string }{
+		{"photos/a.jpg", "photos/a.jpg"},
+		{"photos/2026/b.jpg", "photos/2026/b.jpg"},
+		{"photos/../../../c.jpg", "c.jpg"},
+		{"/etc/.ssh/d", "etc/_ssh/d"},
+		{"photos/a.jpg", "photos/a-1.jpg"},
+	}
+
+	body := bytes.NewBuffer(nil)
+	mw := multipart.NewWriter(body)
+	for _, f := range files {
+		hdr := textproto.MIMEHeader{}
+		hdr.Set("Content-Disposition", fmt.Sprintf(`form-data; name="upload_dir"; filename=%q`, f.name))
+		pw, _ := mw.CreatePart(hdr)
+		pw.Write([]byte(f.stored))
+	}
+	mw.WriteField("comment", "not a file")
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/upload", body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	results := []uploadResult{}
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil || len(results) != len(files) {
+		t.Fatalf("expected %d results, got %q (%v)", len(files), w.Body.String(), err)
+	}
+	for i, f := range files {
+		if results[i].Name != f.stored || results[i].Error != "" {
+			t.Errorf("%q: expected %q, got %+v", f.name, f.stored, results[i])
+		}
+		if data, _ := os.ReadFile(filepath.Join(dir, filepath.FromSlash(f.stored))); string(data) != f.stored {
+			t.Errorf("%q: expected content %q, got %q", f.stored, f.stored, data)
+		}
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestUploadPageRecent(t *testing.T) {
+
+	dir := t.TempDir()
+	naming, _ := ParseUploadNaming("", "")
+	h := UploadHandler(dir, "/upload", UploadOptions{Naming: naming, Recent: RecentOperator})
+
+	r := httptest.NewRequest("PUT", "/upload/recent.txt", bytes.NewBufferString("knut"))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	// a local reverse proxy relays its clients from a loopback address
+	tests := []struct {
+		remote, header string
+		shown          bool
+	}{
+		{"127.0.0.1:1234", "", true},
+		{"[::1]:1234", "", true},
+		{"192.0.2.1:1234", "", false},
+		{"127.0.0.1:1234", "X-Forwarded-For", false},
+		{"127.0.0.1:1234", "Forwarded", false},
+		{"[::1]:1234", "X-Real-Ip", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/upload", nil)
+		r.RemoteAddr = test.remote
+		if test.header != "" {
+			r.Header.Set(test.header, "192.0.2.1")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if shown := bytes.Contains(w.Body.Bytes(), []byte("recent.txt")); shown != test.shown {
+			t.Errorf("%s %s: expected recent uploads shown %v, got %v", test.remote, test.header, test.shown, shown)
+		}
+	}
+}
@@ -0,0 +1,435 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// versionsDir is the hidden folder inside a mapped tree which keeps the
+// prior revisions of the files. it is never served.
+const versionsDir = ".knut-versions"
+
+// Version describes one prior revision of a file.
+type Version struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`     // when the revision was written
+	Replaced time.Time `json:"replaced"` // when it was replaced
+	Remote   string    `json:"remote"`   // who wrote the revision
+	Size     int64     `json:"size"`
+}
+
+// VersionStore keeps prior revisions of the files below 'root' in
+// "root/.knut-versions/<path>/". the newest 'keep' revisions younger than
+// 'maxAge' survive, 0 means no limit.
+type VersionStore struct {
+	root   string
+	keep   int
+	maxAge time.Duration
+
+	mu sync.Mutex
+}
+
+func NewVersionStore(root string, keep int, maxAge time.Duration) *VersionStore {
+	if root == "" {
+		root = "."
+	}
+	return &VersionStore{root: root, keep: keep, maxAge: maxAge}
+}
+
+// storeDir returns the folder keeping the revisions of 'name'
+func (vs *VersionStore) storeDir(name string) (string, error) {
+	rel, err := filepath.Rel(vs.root, name)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", errOutsideRoot
+	}
+	return filepath.Join(vs.root, versionsDir, rel), nil
+}
+
+// BeforeWrite keeps the current content of 'name' as a revision, if
+// 'name' is an existing regular file. call it before overwriting or
+// deleting 'name'.
+func (vs *VersionStore) BeforeWrite(name string) error {
+
+	fi, err := os.Stat(name)
+	if err != nil || !fi.Mode().IsRegular() {
+		return nil
+	}
+
+	dir, err := vs.storeDir(name)
+	if err != nil {
+		return err
+	}
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return err
+	}
+
+	version := Version{Time: fi.ModTime(), Replaced: time.Now(), Size: fi.Size()}
+	if current, err := readVersionMeta(filepath.Join(dir, "current.json")); err == nil {
+		version.Time, version.Remote = current.Time, current.Remote
+	}
+	version.ID = strconv.FormatInt(version.Replaced.UnixNano(), 36)
+
+	if err := copyFile(filepath.Join(dir, version.ID), name); err != nil {
+		return err
+	}
+	if err := writeVersionMeta(filepath.Join(dir, version.ID+".json"), version); err != nil {
+		return err
+	}
+
+	vs.prune(dir)
+	return nil
+}
+
+// BeforeRemove keeps all regular files below 'name' as revisions. call it
+// before removing a file or a whole folder.
+func (vs *VersionStore) BeforeRemove(name string) error {
+	return filepath.WalkDir(name, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == versionsDir {
+			return filepath.SkipDir
+		}
+		if d.Type().IsRegular() {
+			return vs.BeforeWrite(p)
+		}
+		return nil
+	})
+}
+
+// AfterWrite records who wrote the current content of 'name'.
+func (vs *VersionStore) AfterWrite(name, remoteAddr string) error {
+	dir, err := vs.storeDir(name)
+	if err != nil {
+		return err
+	}
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return err
+	}
+	current := Version{ID: "current", Time: time.Now(), Remote: remoteIP(remoteAddr)}
+	if fi, err := os.Stat(name); err == nil {
+		current.Size = fi.Size()
+	}
+	return writeVersionMeta(filepath.Join(dir, "current.json"), current)
+}
+
+// List returns the revisions of 'name', newest first.
+func (vs *VersionStore) List(name string) ([]Version, error) {
+	dir, err := vs.storeDir(name)
+	if err != nil {
+		return nil, err
+	}
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	return listVersions(dir)
+}
+
+// Open opens the revision 'id' of 'name'.
+func (vs *VersionStore) Open(name, id string) (*os.File, Version, error) {
+	dir, err := vs.storeDir(name)
+	if err != nil {
+		return nil, Version{}, err
+	}
+	if !validVersionID(id) {
+		return nil, Version{}, os.ErrNotExist
+	}
+	version, err := readVersionMeta(filepath.Join(dir, id+".json"))
+	if err != nil {
+		return nil, Version{}, err
+	}
+	f, err := os.Open(filepath.Join(dir, id))
+	return f, version, err
+}
+
+// Restore makes revision 'id' the current content of 'name', the
+// replaced content becomes a revision itself.
+func (vs *VersionStore) Restore(name, id, remoteAddr string) error {
+	f, _, err := vs.Open(name, id)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := vs.BeforeWrite(name); err != nil {
+		return err
+	}
+	if _, err := writeFileAtomic(name, f); err != nil {
+		return err
+	}
+	return vs.AfterWrite(name, remoteAddr)
+}
+
+// prune removes the revisions in 'dir' exceeding 'keep' or 'maxAge'.
+func (vs *VersionStore) prune(dir string) {
+	versions, err := listVersions(dir)
+	if err != nil {
+		return
+	}
+	for i, version := range versions {
+		tooMany := vs.keep > 0 && i >= vs.keep
+		tooOld := vs.maxAge > 0 && time.Since(version.Replaced) > vs.maxAge
+		if tooMany || tooOld {
+			os.Remove(filepath.Join(dir, version.ID))
+			os.Remove(filepath.Join(dir, version.ID+".json"))
+		}
+	}
+}
+
+func listVersions(dir string) ([]Version, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Version{}, nil
+		}
+		return nil, err
+	}
+	versions := []Version{}
+	for _, entry := range entries {
+		id, isMeta := strings.CutSuffix(entry.Name(), ".json")
+		if !isMeta || id == "current" {
+			continue
+		}
+		if version, err := readVersionMeta(filepath.Join(dir, entry.Name())); err == nil {
+			versions = append(versions, version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Replaced.After(versions[j].Replaced)
+	})
+	return versions, nil
+}
+
+func validVersionID(id string) bool {
+	if id == "" || id == "current" {
+		return false
+	}
+	_, err := strconv.ParseInt(id, 36, 64)
+	return err == nil
+}
+
+func readVersionMeta(name string) (Version, error) {
+	version := Version{}
+	data, err := os.ReadFile(name)
+	if err == nil {
+		err = json.Unmarshal(data, &version)
+	}
+	return version, err
+}
+
+func writeVersionMeta(name string, version Version) error {
+	data, _ := json.Marshal(version)
+	return os.WriteFile(name, data, 0o666)
+}
+
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// writeFileAtomic replaces 'name' with the content of 'src' via a
+// temporary file in the same folder: readers never see a half written
+// file.
+func writeFileAtomic(name string, src io.Reader) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".knut-*")
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(tmp, src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return n, err
+}
+
+func remoteIP(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
+
+// isVersionsPath reports if the slash separated 'p' points into a
+// versionsDir.
+func isVersionsPath(p string) bool {
+	for _, element := range strings.Split(p, "/") {
+		if element == versionsDir {
+			return true
+		}
+	}
+	return false
+}
+
+// hideVersionsFS hides the versionsDir from the listings and from being
+// opened.
+type hideVersionsFS struct {
+	http.FileSystem
+}
+
+func (hfs hideVersionsFS) Open(name string) (http.File, error) {
+	if isVersionsPath(name) {
+		return nil, os.ErrNotExist
+	}
+	f, err := hfs.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return hideVersionsFile{f}, nil
+}
+
+type hideVersionsFile struct {
+	http.File
+}
+
+func (hf hideVersionsFile) Readdir(count int) ([]os.FileInfo, error) {
+	entries, err := hf.File.Readdir(count)
+	return slices.DeleteFunc(entries, func(fi os.FileInfo) bool {
+		return fi.Name() == versionsDir
+	}), err
+}
+
+// VersionsHandler offers the revisions of the files 'resolve' maps the
+// requests to:
+//
+//	GET  file?versions        - list the revisions (html or json)
+//	GET  file?version=<id>    - download revision <id>
+//	POST file?restore=<id>    - make revision <id> the current content
+//
+// all other requests are passed to 'next'.
+func VersionsHandler(next http.Handler, vs *VersionStore, resolve func(*http.Request) (string, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if isVersionsPath(r.URL.Path) {
+			http.NotFound(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		_, list := query["versions"]
+		id, restoreID := query.Get("version"), query.Get("restore")
+		if !list && id == "" && restoreID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		name, err := resolve(r)
+		if err != nil {
+			writeStatus(w, http.StatusForbidden)
+			return
+		}
+
+		switch {
+		case restoreID != "" && r.Method == http.MethodPost:
+			if !sameOrigin(r) {
+				writeStatus(w, http.StatusForbidden)
+				return
+			}
+			if err := vs.Restore(name, restoreID, r.RemoteAddr); err != nil {
+				w.WriteHeader(statusForFSError(err))
+				fmt.Fprintf(w, "restoring %q: %v\n", restoreID, err)
+				return
+			}
+			AddLogNote(r, "restore %q of %q", restoreID, r.URL.Path)
+			http.Redirect(w, r, r.URL.Path+"?versions", http.StatusSeeOther)
+		case r.Method != http.MethodGet && r.Method != http.MethodHead:
+			writeStatus(w, http.StatusMethodNotAllowed)
+		case id != "":
+			f, version, err := vs.Open(name, id)
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			defer f.Close()
+			w.Header().Set("Content-Type", contentTypeOfFile(name))
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", version.ID+"-"+filepath.Base(name)))
+			http.ServeContent(w, r, "", version.Time, f)
+		default:
+			versions, err := vs.List(name)
+			if err != nil {
+				writeStatus(w, http.StatusInternalServerError)
+				return
+			}
+			if strings.Contains(r.Header.Get("Accept"), "application/json") {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(versions)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			versionsTmpl.Execute(w, struct {
+				Name     string
+				Versions []Version
+			}{path.Base(r.URL.Path), versions})
+		}
+	})
+}
+
+var versionsTmpl = template.Must(template.New("versions").Parse(`<!doctype html>
+<html>
+<head>
+	<title>knut - versions of {{ .Name }}</title>
+	<style type="text/css">
+* { font-family: monospace }
+td { padding: 0.1em 1em 0.1em 0 }
+	</style>
+</head>
+<body>
+<h1>knut - versions of <a href="{{ .Name }}">{{ .Name }}</a></h1>
+<table>
+<tr><th>written</th><th>by</th><th>size</th><th>replaced</th><th></th></tr>
+{{- range .Versions }}
+<tr>
+	<td><a href="?version={{ .ID }}">{{ .Time.Format "2006-01-02 15:04:05" }}</a></td>
+	<td>{{ .Remote }}</td>
+	<td>{{ .Size }}</td>
+	<td>{{ .Replaced.Format "2006-01-02 15:04:05" }}</td>
+	<td><form method="post" action="?restore={{ .ID }}"><input type="submit" value="restore"></form></td>
+</tr>
+{{- else }}
+<tr><td colspan="5">no prior versions</td></tr>
+{{- end }}
+</table>
+</body>
+</html>
+`))
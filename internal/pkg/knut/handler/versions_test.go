@@ -0,0 +1,81 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVersionsWebDAV(t *testing.T) {
+
+	dir := t.TempDir()
+	vs := NewVersionStore(dir, 2, 0)
+	server := httptest.NewServer(WebDAVHandler(dir, "/", false, vs))
+	defer server.Close()
+
+	do := func(method, path, body string) (int, string) {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		req.Header.Set("Accept", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+	list := func() []Version {
+		versions := []Version{}
+		_, body := do("GET", "/a.txt?versions", "")
+		json.Unmarshal([]byte(body), &versions)
+		return versions
+	}
+
+	for _, content := range []string{"v1", "v2", "v3", "v4"} {
+		if code, _ := do("PUT", "/a.txt", content); code >= 300 {
+			t.Fatalf("PUT %q: got %d", content, code)
+		}
+	}
+
+	versions := list()
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(versions))
+	}
+	if _, body := do("GET", "/a.txt?version="+versions[0].ID, ""); body != "v3" {
+		t.Errorf("expected newest revision to be %q, got %q", "v3", body)
+	}
+	if versions[0].Remote != "127.0.0.1" {
+		t.Errorf("expected remote %q, got %q", "127.0.0.1", versions[0].Remote)
+	}
+
+	if code, _ := do("POST", "/a.txt?restore="+versions[1].ID, ""); code != http.StatusOK {
+		t.Errorf("restore: expected %d (after redirect), got %d", http.StatusOK, code)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "v2" {
+		t.Errorf("expected restored content %q, got %q", "v2", data)
+	}
+
+	if code, _ := do("DELETE", "/a.txt", ""); code != http.StatusNoContent {
+		t.Errorf("DELETE: expected %d, got %d", http.StatusNoContent, code)
+	}
+	if versions = list(); len(versions) != 2 || versions[0].Size != 2 {
+		t.Errorf("expected the deleted content as newest revision, got %v", versions)
+	}
+
+	for _, path := range []string{"/" + versionsDir + "/", "/" + versionsDir + "/a.txt/current.json"} {
+		if code, _ := do("GET", path, ""); code != http.StatusNotFound {
+			t.Errorf("GET %s: expected %d, got %d", path, http.StatusNotFound, code)
+		}
+	}
+	if _, body := do("PROPFIND", "/", ""); strings.Contains(body, versionsDir) {
+		t.Errorf("PROPFIND lists %q", versionsDir)
+	}
+}
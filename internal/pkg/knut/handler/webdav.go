@@ -0,0 +1,179 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/webdav"
+)
+
+// WebDAVHandler serves 'dir' via WebDAV (class 1 and 2: PROPFIND, PROPPATCH,
+// MKCOL, PUT, DELETE, MOVE, COPY, LOCK and UNLOCK) below 'uri', the tree
+// can be mounted via davfs2, gio, Finder or the Windows Explorer. locks
+// are kept in memory.
+//
+// GET and HEAD are served like a regular directory mapping, which gives
+// browsers a listing instead of the "405 Method Not Allowed" a WebDAV
+// server answers for collections.
+//
+// if 'readOnly' is set, all methods altering the tree are rejected. if
+// 'versions' is given, files replaced or deleted via PUT, DELETE, COPY and
+// MOVE are kept as revisions, see VersionsHandler.
+func WebDAVHandler(dir, uri string, readOnly bool, versions *VersionStore) http.Handler {
+
+	if dir == "" { // "webdav://." yields "" after url.Parse()
+		dir = "."
+	}
+
+	dav := &webdav.Handler{
+		Prefix:     strings.TrimSuffix(uri, "/"),
+		FileSystem: hideVersionsDAV{webdav.Dir(dir)},
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(r *http.Request, err error) {
+			if err != nil && !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "warning: webdav %s %q: %v\n", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+	files := FileOrDirHandler(dir, uri)
+	resolve := func(r *http.Request) (string, error) {
+		return containedPath(dir, strings.TrimPrefix(r.URL.Path, dav.Prefix))
+	}
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			files.ServeHTTP(w, r)
+			return
+		case http.MethodOptions, "PROPFIND":
+		default:
+			if readOnly {
+				w.Header().Set("Allow", "OPTIONS, GET, HEAD, PROPFIND")
+				writeStatus(w, http.StatusMethodNotAllowed)
+				return
+			}
+		}
+		if versions != nil {
+			serveDAVVersioned(dav, versions, resolve, w, r)
+			return
+		}
+		dav.ServeHTTP(w, r)
+	})
+
+	if versions != nil {
+		handler = VersionsHandler(handler, versions, resolve)
+		if readOnly { // no restore
+			handler = onlyMethods(handler, http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND")
+		}
+	}
+	return handler
+}
+
+func onlyMethods(next http.Handler, methods ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !slices.Contains(methods, r.Method) {
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+			writeStatus(w, http.StatusMethodNotAllowed)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveDAVVersioned keeps the revisions of the files a request is about
+// to replace or remove before handing it to 'dav'.
+func serveDAVVersioned(dav *webdav.Handler, versions *VersionStore, resolve func(*http.Request) (string, error),
+	w http.ResponseWriter, r *http.Request) {
+
+	target := ""
+	switch r.Method {
+	case http.MethodPut, http.MethodDelete:
+		target, _ = resolve(r)
+	case "COPY", "MOVE":
+		if r.Header.Get("Overwrite") == "F" {
+			break
+		}
+		if u, err := url.Parse(r.Header.Get("Destination")); err == nil {
+			target, _ = resolve(&http.Request{URL: u})
+		}
+	}
+
+	if target == "" {
+		dav.ServeHTTP(w, r)
+		return
+	}
+	if err := versions.BeforeRemove(target); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "warning: keeping revision of %q: %v\n", target, err)
+		writeStatus(w, http.StatusInternalServerError)
+		return
+	}
+
+	sc := &statusCodeCapture{w: w, code: http.StatusOK}
+	dav.ServeHTTP(sc, r)
+	if r.Method != http.MethodDelete && sc.code >= 200 && sc.code < 300 {
+		versions.AfterWrite(target, r.RemoteAddr)
+	}
+}
+
+// hideVersionsDAV hides the versionsDir from WebDAV clients.
+type hideVersionsDAV struct {
+	webdav.FileSystem
+}
+
+func (hfs hideVersionsDAV) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	if isVersionsPath(name) {
+		return os.ErrPermission
+	}
+	return hfs.FileSystem.Mkdir(ctx, name, perm)
+}
+
+func (hfs hideVersionsDAV) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if isVersionsPath(name) {
+		return nil, os.ErrNotExist
+	}
+	f, err := hfs.FileSystem.OpenFile(ctx, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return hideVersionsDAVFile{f}, nil
+}
+
+func (hfs hideVersionsDAV) RemoveAll(ctx context.Context, name string) error {
+	if isVersionsPath(name) {
+		return os.ErrNotExist
+	}
+	return hfs.FileSystem.RemoveAll(ctx, name)
+}
+
+func (hfs hideVersionsDAV) Rename(ctx context.Context, oldName, newName string) error {
+	if isVersionsPath(oldName) || isVersionsPath(newName) {
+		return os.ErrPermission
+	}
+	return hfs.FileSystem.Rename(ctx, oldName, newName)
+}
+
+func (hfs hideVersionsDAV) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	if isVersionsPath(name) {
+		return nil, os.ErrNotExist
+	}
+	return hfs.FileSystem.Stat(ctx, name)
+}
+
+type hideVersionsDAVFile struct {
+	webdav.File
+}
+
+func (hf hideVersionsDAVFile) Readdir(count int) ([]os.FileInfo, error) {
+	entries, err := hf.File.Readdir(count)
+	return slices.DeleteFunc(entries, func(fi os.FileInfo) bool {
+		return fi.Name() == versionsDir
+	}), err
+}
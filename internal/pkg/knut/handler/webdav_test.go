@@ -0,0 +1,69 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWebDAVHandler(t *testing.T) {
+
+	dir := t.TempDir()
+	mux := http.NewServeMux()
+	mux.Handle("/dav/", WebDAVHandler(dir, "/dav/", false, nil))
+	mux.Handle("/ro/", WebDAVHandler(dir, "/ro/", true, nil))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	do := func(method, path, body string, hdr ...string) int {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		for i := 0; i+1 < len(hdr); i += 2 {
+			req.Header.Set(hdr[i], hdr[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode
+	}
+
+	steps := []struct {
+		method, path, body string
+		hdr                []string
+		code               int
+	}{
+		{"MKCOL", "/dav/sub", "", nil, http.StatusCreated},
+		{"PUT", "/dav/sub/a.txt", "knut", nil, http.StatusCreated},
+		{"PROPFIND", "/dav/sub/", "", []string{"Depth", "1"}, http.StatusMultiStatus},
+		{"GET", "/dav/sub/a.txt", "", nil, http.StatusOK},
+		{"GET", "/dav/sub/", "", nil, http.StatusOK},
+		{"COPY", "/dav/sub/a.txt", "", []string{"Destination", server.URL + "/dav/b.txt"}, http.StatusCreated},
+		{"MOVE", "/dav/b.txt", "", []string{"Destination", server.URL + "/dav/c.txt"}, http.StatusCreated},
+		{"PUT", "/ro/d.txt", "knut", nil, http.StatusMethodNotAllowed},
+		{"DELETE", "/ro/c.txt", "", nil, http.StatusMethodNotAllowed},
+		{"PROPFIND", "/ro/", "", []string{"Depth", "1"}, http.StatusMultiStatus},
+		{"DELETE", "/dav/c.txt", "", nil, http.StatusNoContent},
+	}
+
+	for i, step := range steps {
+		if code := do(step.method, step.path, step.body, step.hdr...); code != step.code {
+			t.Errorf("step %d: %s %s expected %d, got %d", i, step.method, step.path, step.code, code)
+		}
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dir, "sub", "a.txt")); string(data) != "knut" {
+		t.Errorf("expected sub/a.txt to contain %q, got %q", "knut", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "c.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected c.txt to be deleted, got %v", err)
+	}
+}
@@ -55,7 +55,7 @@ func ZipFSHandler(name, prefix, index string) http.Handler {
 				continue
 			}
 			if file.Mode().IsRegular() {
-				serveZipEntry(w, file)
+				serveZipEntry(w, r, file)
 				return
 			}
 			break
@@ -65,7 +65,18 @@ func ZipFSHandler(name, prefix, index string) http.Handler {
 	})
 }
 
-func serveZipEntry(w http.ResponseWriter, zFile *zip.File) {
+func serveZipEntry(w http.ResponseWriter, r *http.Request, zFile *zip.File) {
+
+	// the zip directory already knows checksum and size of the entry,
+	// a strong validator without reading the entry.
+	etag := fmt.Sprintf(`"%08x-%x"`, zFile.CRC32, zFile.UncompressedSize64)
+	w.Header().Set("ETag", etag)
+	if !zFile.Modified.IsZero() {
+		w.Header().Set("Last-Modified", zFile.Modified.UTC().Format(http.TimeFormat))
+	}
+	if checkNotModified(w, r, etag, zFile.Modified) {
+		return
+	}
 
 	zr, err := zFile.Open()
 	if err != nil {
@@ -0,0 +1,189 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package knut
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mgumz/knut/internal/pkg/knut/handler"
+)
+
+// ReplayOpts configure Replay
+type ReplayOpts struct {
+	Target      string        // scheme and host the requests are sent to
+	Host        string        // "Host" header, the one of Target if empty
+	Headers     http.Header   // set on each request, an empty value removes the header
+	Concurrency int           // requests in flight, at least 1
+	Rate        float64       // requests per second, 0: no limit
+	Timeout     time.Duration // per request, 0: no limit
+}
+
+// ReplayResult compares the replay of a request with its recording.
+type ReplayResult struct {
+	Method, Path string
+	Recorded     int
+	Status       int
+	Truncated    bool // the recorded body was cut, only its start was sent
+	Err          error
+}
+
+// the headers a transport sets on its own
+var replaySkipHeaders = []string{"Host", "Content-Length", "Connection", "Transfer-Encoding", "Keep-Alive", "Upgrade", "Te", "Trailer"}
+
+// ReadHAR reads the HAR file 'name'.
+func ReadHAR(name string) (*handler.HAR, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	har := &handler.HAR{}
+	if err := json.Unmarshal(data, har); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return har, nil
+}
+
+// Replay sends the requests of 'har' to opts.Target, with their original
+// method, path, headers and body. the results are in the order of 'har'.
+func Replay(har *handler.HAR, opts ReplayOpts) ([]ReplayResult, error) {
+
+	target, err := url.Parse(opts.Target)
+	if err != nil || target.Host == "" {
+		return nil, fmt.Errorf("invalid target %q", opts.Target)
+	}
+	client := &http.Client{
+		Timeout:       opts.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	entries := har.Log.Entries
+	results := make([]ReplayResult, len(entries))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < max(opts.Concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = replayEntry(client, target, entries[i], opts)
+			}
+		}()
+	}
+
+	var tick <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for i := range entries {
+		if tick != nil && i > 0 {
+			<-tick
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, nil
+}
+
+func replayEntry(client *http.Client, target *url.URL, entry handler.HAREntry, opts ReplayOpts) ReplayResult {
+
+	recorded := entry.Request
+	result := ReplayResult{Method: recorded.Method, Path: recorded.URL, Recorded: entry.Response.Status}
+
+	orig, err := url.Parse(recorded.URL)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Path = orig.RequestURI()
+	body, err := recorded.PostData.Body()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Truncated = recorded.PostData != nil && int64(len(body)) < recorded.BodySize
+
+	u := *target
+	u.Path, u.RawPath, u.RawQuery = strings.TrimSuffix(target.Path, "/")+orig.Path, "", orig.RawQuery
+	if orig.RawPath != "" {
+		u.RawPath = strings.TrimSuffix(target.EscapedPath(), "/") + orig.RawPath
+	}
+	req, err := http.NewRequest(recorded.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	for _, h := range recorded.Headers {
+		if !replaySkip(h.Name) {
+			req.Header.Add(h.Name, h.Value)
+		}
+	}
+	for name, values := range opts.Headers {
+		req.Header.Del(name)
+		for _, v := range values {
+			if v != "" {
+				req.Header.Add(name, v)
+			}
+		}
+	}
+	if opts.Host != "" {
+		req.Host = opts.Host
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	result.Status = resp.StatusCode
+	return result
+}
+
+func replaySkip(name string) bool {
+	for _, skip := range replaySkipHeaders {
+		if strings.EqualFold(name, skip) {
+			return true
+		}
+	}
+	return strings.HasPrefix(name, ":") // http/2 pseudo headers
+}
+
+// PrintReplaySummary writes one line per result, marking the ones whose
+// status differs from the recording, and the totals. it returns the
+// number of differing and failed requests.
+func PrintReplaySummary(w io.Writer, results []ReplayResult) int {
+	differ, failed := 0, 0
+	for _, r := range results {
+		mark, status := " ", fmt.Sprint(r.Status)
+		switch {
+		case r.Err != nil:
+			mark, status = "!", "error: "+r.Err.Error()
+			failed++
+		case r.Status != r.Recorded:
+			mark = "≠"
+			differ++
+		}
+		note := ""
+		if r.Truncated {
+			note = " (body was truncated)"
+		}
+		fmt.Fprintf(w, "%s %-7s %s\t%d -> %s%s\n", mark, r.Method, r.Path, r.Recorded, status, note)
+	}
+	fmt.Fprintf(w, "\n%d requests, %d same status, %d different, %d failed\n", len(results), len(results)-differ-failed, differ, failed)
+	return differ + failed
+}
@@ -0,0 +1,86 @@
+// Copyright 2026 Mathias Gumz. All rights reserved. Use of this source code
+// is governed by a BSD-style license that can be found in the LICENSE file.
+
+package knut
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mgumz/knut/internal/pkg/knut/handler"
+)
+
+func TestReplay(t *testing.T) {
+
+	type seen struct{ method, uri, host, body, agent, drop string }
+	mu := sync.Mutex{}
+	got := map[string]seen{}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got[r.URL.Path] = seen{r.Method, r.RequestURI, r.Host, string(body), r.Header.Get("User-Agent"), r.Header.Get("X-Drop")}
+		mu.Unlock()
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer target.Close()
+
+	entry := func(method, url string, status int, body *handler.HARPostData, size int64) handler.HAREntry {
+		return handler.HAREntry{
+			Request: handler.HARRequest{Method: method, URL: url, PostData: body, BodySize: size,
+				Headers: []handler.HARNameValue{{Name: "User-Agent", Value: "recorded"}, {Name: "X-Drop", Value: "1"}, {Name: "Content-Length", Value: "99"}}},
+			Response: handler.HARResponse{Status: status},
+		}
+	}
+	har := &handler.HAR{}
+	har.Log.Entries = []handler.HAREntry{
+		entry("GET", "http://recorded.example/a?x=1", 200, nil, 0),
+		entry("POST", "http://recorded.example/b", 201, &handler.HARPostData{Text: "aGVsbG8=", Encoding: "base64"}, 5),
+		entry("PUT", "http://recorded.example/missing", 200, &handler.HARPostData{Text: "cut"}, 10),
+	}
+
+	results, err := Replay(har, ReplayOpts{
+		Target:      target.URL,
+		Host:        "rewritten.example",
+		Headers:     http.Header{"User-Agent": {"replay"}, "X-Drop": {""}},
+		Concurrency: 2,
+		Rate:        100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path      string
+		seen      seen
+		status    int
+		truncated bool
+	}{
+		{"/a", seen{"GET", "/a?x=1", "rewritten.example", "", "replay", ""}, 200, false},
+		{"/b", seen{"POST", "/b", "rewritten.example", "hello", "replay", ""}, 200, false},
+		{"/missing", seen{"PUT", "/missing", "rewritten.example", "cut", "replay", ""}, 404, true},
+	}
+	for i, test := range tests {
+		if got[test.path] != test.seen {
+			t.Errorf("%s: expected %+v, got %+v", test.path, test.seen, got[test.path])
+		}
+		r := results[i]
+		if r.Err != nil || r.Status != test.status || r.Truncated != test.truncated {
+			t.Errorf("%s: unexpected result %+v", test.path, r)
+		}
+	}
+
+	out := bytes.NewBuffer(nil)
+	if n := PrintReplaySummary(out, results); n != 2 {
+		t.Errorf("expected 2 differences, got %d:\n%s", n, out)
+	}
+	if !strings.Contains(out.String(), "3 requests, 1 same status, 2 different, 0 failed") {
+		t.Errorf("unexpected summary:\n%s", out)
+	}
+}
@@ -18,7 +18,8 @@ import (
 )
 
 type OnetimeTLS struct {
-	Listener net.Listener
+	Listener   net.Listener
+	ClientAuth tls.ClientAuthType // set before Create
 
 	privKey      *ecdsa.PrivateKey
 	sn           *big.Int
@@ -115,6 +116,7 @@ func (ot *OnetimeTLS) fillTLSConfig() {
 		ot.tlsConfig.MinVersion = tls.VersionTLS11
 		ot.tlsConfig.CurvePreferences = []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256}
 		ot.tlsConfig.SessionTicketsDisabled = true
+		ot.tlsConfig.ClientAuth = ot.ClientAuth
 		ot.tlsConfig.Certificates = make([]tls.Certificate, 1)
 		ot.tlsConfig.Certificates[0], ot.err = tls.X509KeyPair(ot.certBytes, ot.privKeyBytes)
 	}
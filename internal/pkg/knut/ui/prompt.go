@@ -4,6 +4,8 @@
 package ui
 
 import (
+	"context"
+	"fmt"
 	"net"
 	"net/netip"
 	"slices"
@@ -25,3 +27,21 @@ func PromptBindAddr(netAddrs []net.Addr) (string, error) {
 
 	return promptHuh(addrs)
 }
+
+// the answers of PromptUploadApproval
+const (
+	UploadAccept    = "accept"
+	UploadReject    = "reject"
+	UploadAcceptAll = "accept all"
+)
+
+// PromptUploadApproval asks if the file 'name' of 'size' bytes and type
+// 'ctype', sent by 'sender', is accepted. the question is dropped once
+// 'ctx' is done.
+func PromptUploadApproval(ctx context.Context, sender, name string, size int64, ctype string) (string, error) {
+
+	title := fmt.Sprintf("Accept upload %q?", name)
+	details := fmt.Sprintf("from %s, %d bytes, %s", sender, size, ctype)
+
+	return promptHuhChoice(ctx, title, details, []string{UploadAccept, UploadReject, UploadAcceptAll})
+}
@@ -4,6 +4,8 @@
 package ui
 
 import (
+	"context"
+
 	"github.com/charmbracelet/huh"
 )
 
@@ -22,3 +24,21 @@ func promptHuh(addrs []string) (string, error) {
 
 	return pickedAddr, nil
 }
+
+func promptHuhChoice(ctx context.Context, title, description string, choices []string) (string, error) {
+
+	picked := ""
+
+	choice := huh.NewSelect[string]().
+		Title(title).
+		Description(description).
+		Options(huh.NewOptions(choices...)...).
+		Value(&picked)
+	form := huh.NewForm(huh.NewGroup(choice)).WithShowHelp(false)
+
+	if err := form.RunWithContext(ctx); err != nil {
+		return "", err
+	}
+
+	return picked, nil
+}
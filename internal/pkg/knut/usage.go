@@ -21,11 +21,122 @@ Mapping Format:
    /:.                     - list contents of current directory via "/"
    /uri:folder             - list contents of "folder" via "/uri"
    /uri:file               - serve "file" via "/uri"
+                             for folders and files: an existing "file.br",
+                             "file.zst" or "file.gz" is served instead of
+                             "file", if the client accepts that encoding
+   /uri:file://folder      - same as "/uri:folder", query-options:
+                             cache - "Cache-Control" rules for this mapping,
+                             relative to "/uri", see -cache
+                             spa - serve the single-page-application in
+                             "folder": unknown paths without a file extension
+                             and directories are answered with
+                             "folder/index.html"
+                             base - inject <base href="base"> into the
+                             index.html of the spa
+                             writable - render folders as file manager:
+                             create folders, move, delete and upload files
+                             versions[=n] - keep the last n (default 10)
+                             revisions of replaced or deleted files, list
+                             them via "file?versions", restore them
+                             versions-age - drop revisions older than this
+   /uri:-                  - stream stdin to the first GET of "/uri", later
+                             ones get "410 Gone" ("pg_dump | knut /db.sql:-").
+                             query-options ("/uri:-?every&exit"):
+                             every - every GET gets all of stdin, which is
+                             spooled to a temporary file
+                             exit - stop knut after the first complete
+                             download
    /uri:@text              - respond with "text" at "/uri"
    30x/uri:location        - respond with 301 at "/uri"
    @/upload:folder         - accept multipart encoded data via POST at "/upload"
                              and store it inside "folder". A simple upload form
-                             is rendered on GET.
+                             is rendered on GET, uploaded folders keep their
+                             structure. Raw bodies PUT or POSTed to
+                             "/upload/name" are stored as "folder/name"
+                             ("curl -T file http://host/upload/"), answered
+                             with JSON (name, size, sha256). "Content-Range"
+                             resumes interrupted uploads.
+   @/upload:file://folder  - same as above, query-options:
+                             naming - "original" (default), "random" or a
+                             template like "{date}/{ip}_{name}", fields:
+                             name, base, ext, date, time, ip, port, rand
+                             collision - "suffix" (default, "a-1.txt"),
+                             "overwrite" or "reject"
+                             versions, versions-age - keep overwritten
+                             files, see file://
+                             max-file-size, max-request-size, quota - size
+                             limits per file, request and for the whole
+                             "folder" ("512k", "10M", "2G")
+                             max-files - files per request
+                             hook - command to run (via "sh -c") for each
+                             received file, environment: KNUT_UPLOAD_PATH,
+                             KNUT_UPLOAD_NAME, KNUT_UPLOAD_SIZE,
+                             KNUT_UPLOAD_SHA256, KNUT_UPLOAD_REMOTE
+                             hook-timeout - default "1m"
+                             hook-concurrency - parallel hooks, default 1
+                             hook-fail - if the hook fails: "keep" (default,
+                             the hook runs in the background), "reject" or
+                             "quarantine" (moved to ".knut-quarantine"),
+                             both stage the file in ".knut-pending" until
+                             the hook succeeded
+                             recent - who sees the recently received files
+                             on the upload page: "operator" (default, see
+                             "Operator" below), "all" or "off"
+                             allow, deny - extensions and sniffed MIME types,
+                             e.g. "deny=.exe,application/x-msdownload",
+                             "allow=image/*,.pdf"
+                             extract - unpack received zip, tar and tar.gz
+                             files into a folder named after them, "keep"
+                             (default) or "discard" the archive
+                             extract-max-entries - default 10000
+                             extract-max-size - expanded size, default "1G"
+                             approve - hold each received file in
+                             ".knut-pending" and ask on the terminal to
+                             accept, reject (403) or accept all (the rest
+                             of the same request), the collision policy
+                             applies to accepted files
+                             approve-timeout - reject unanswered files
+                             after this, default "5m" ("0": wait as long
+                             as the client does)
+   @/in:-                  - write the bodies PUT or POSTed to "/in" to stdout,
+                             knut's own output goes to stderr then.
+                             query-options ("@/in:-?exit"):
+                             exit - stop knut after the first body
+   /tus:tus://folder       - accept resumable uploads via the tus protocol 1.0
+                             at "/tus" (creation, termination, expiration),
+                             incomplete uploads are kept in "folder",
+                             query-options:
+                             expire - drop incomplete uploads after this
+                             (default "24h", "0" keeps them)
+                             naming, collision, max-file-size, quota, allow,
+                             deny, ... - see @/upload:file://folder
+   /p:paste://folder       - a pastebin at "/p": text POSTed as form field
+                             "text" or as raw body ("curl --data-binary @f")
+                             is kept in "folder", the reply names its URL
+                             "/p/<id>", "/p/<id>?raw" shows it as plain text,
+                             "lang", "expire" and "burn" (remove after the
+                             first read) are form fields or query-options of
+                             the POST. query-options of the mapping:
+                             expire - default and longest lifetime ("7d")
+                             max-size - per paste, default "1M"
+                             list - who sees the pastes on "/p": "operator"
+                             (default, see "Operator" below), "all" or "off"
+   /pipe:pipe://           - streams the body PUT or POSTed to "/pipe/<id>" to
+                             the GET of "/pipe/<id>", nothing is stored. both
+                             sides wait for each other, "?n=3" on all sides
+                             waits for 3 receivers. "curl -T file host/pipe/x"
+                             and "curl host/pipe/x > file". query-options:
+                             timeout - how long to wait, default "5m"
+   /hook:bin://            - a request bin: answers any request to "/hook" and
+                             below with "ok" and keeps it. "/hook/.knut/"
+                             inspects the requests live (headers, query, JSON
+                             and form bodies), "/hook/.knut/requests" is the
+                             JSON API. "bin://folder" keeps them in "folder"
+                             as well. query-options:
+                             keep - number of requests kept, default 100
+                             max-body - recorded per body, default "1M"
+                             view - who inspects: "operator" (default, see
+                             "Operator" below) or "all"
    /c.tgz:tar+gz://./      - creates a (gzipped) tarball from the current directory
                              and serves it via "/c.tgz"
    /z.zip:zip://./         - creates a zip files from the current directory
@@ -33,7 +144,12 @@ Mapping Format:
    /z.zip:zipfs://a.zip    - list and servce the content of the entries of an
                              existing "z.zip" via the "/z.zip": consider a file
                              "example.txt" inside "z.zip", it will be directly
-                             available via "/z.zip/example.txt"
+                             available via "/z.zip/example.txt", query-options:
+                             cache - "Cache-Control" rules, see file://
+   /uri/:webdav://dir      - serves "dir" via WebDAV at "/uri/", mountable as
+                             network drive (davfs2, gio, Finder, Explorer),
+                             query-options: versions, versions-age, see file://
+   /uri/:webdav+ro://dir   - same as webdav://, but read-only
    /uri:http://1.2.3.4/    - creates a reverse proxy and forwards requests to /uri
                              to the given http-host
    /uri:git://folder/      - serves files via "git http-backend"
@@ -42,6 +158,28 @@ Mapping Format:
                              fuzzy - /24 for ipv4; /56 for ipv6
                              info - api to use for meta data about the ip
                              supported: "ripe"
+   /uri:echo://            - answers with everything knut sees about the
+                             request: method, url, headers, protocol, remote
+                             and local address, tls version, cipher suite,
+                             sni, alpn and client certificates (asked for
+                             via -tls-client-cert). as json, html or plain
+                             text, depending on "Accept"
+
+Operator:
+
+   "operator" is whoever connects from the local machine (127.0.0.1, ::1)
+   without "Forwarded", "X-Forwarded-For" or "X-Real-IP" headers. knut
+   can't tell the clients of a local relay apart: behind a reverse proxy
+   that omits these headers or a tunnel ("ssh -L", stunnel, socat),
+   EVERY client is the operator and sees the received files, the pastes
+   and the requests of a bin (with their "Authorization" headers). use
+   "off" or -auth in such setups.
+
+Subcommands:
+
+   knut replay [opts] capture.har - re-send the requests recorded via
+                             -capture to -target and compare the status
+                             codes, see "knut replay -h"
 
 `
 